@@ -16,7 +16,9 @@ import (
 	"github.com/ava-labs/avalanchego/snow/consensus/avalanche"
 	"github.com/ava-labs/avalanchego/snow/consensus/snowstorm"
 	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/avalanchego/utils/set"
 	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/consensus"
+	"go.uber.org/zap"
 )
 
 const (
@@ -56,7 +58,12 @@ func (tx *MockTx) Status() choices.Status {
 
 // Bytes implements snowstorm.Tx
 func (tx *MockTx) Bytes() []byte {
-	return tx.id.Bytes()
+	return tx.id[:]
+}
+
+// MissingDependencies implements snowstorm.Tx
+func (tx *MockTx) MissingDependencies() (set.Set[ids.ID], error) {
+	return set.Empty[ids.ID](), nil
 }
 
 // Verify implements snowstorm.Tx
@@ -118,6 +125,16 @@ func (v *MockVertex) Bytes() []byte {
 	return v.bytes
 }
 
+// Verify implements ParallelVertex by verifying every transaction
+func (v *MockVertex) Verify(ctx context.Context) error {
+	for _, tx := range v.txs {
+		if err := tx.Verify(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetProcessingPriority returns the vertex priority
 func (v *MockVertex) GetProcessingPriority() uint64 {
 	return v.height
@@ -197,7 +214,8 @@ func main() {
 	
 	// Create logger
 	logFactory := logging.NewFactory(logging.Config{
-		DisplayLevel: "info",
+		DisplayLevel: logging.Info,
+		LogLevel:     logging.Info,
 	})
 	log, err := logFactory.Make("benchmark")
 	if err != nil {
@@ -209,7 +227,7 @@ func main() {
 	ctx := context.Background()
 	
 	// Run benchmark
-	log.Info("Creating DAG with %d vertices", *numVertices)
+	log.Info("Creating DAG", zap.Int("vertices", *numVertices))
 	vertices := createMockDAG(*numVertices)
 	
 	// Sequential processing
@@ -219,39 +237,39 @@ func main() {
 		for _, vertex := range vertices {
 			txs, err := vertex.Txs(ctx)
 			if err != nil {
-				log.Error("Failed to get txs: %s", err)
+				log.Error("Failed to get txs", zap.Error(err))
 				continue
 			}
 			
 			for _, tx := range txs {
 				err = tx.Verify(ctx)
 				if err != nil {
-					log.Error("Failed to verify tx: %s", err)
+					log.Error("Failed to verify tx", zap.Error(err))
 				}
 			}
 		}
 	}
 	sequentialDuration := time.Since(sequentialStart)
-	log.Info("Sequential processing took %s", sequentialDuration)
+	log.Info("Sequential processing finished", zap.Duration("duration", sequentialDuration))
 	
 	// Parallel processing
-	log.Info("Running parallel processing benchmark with %d threads", *numThreads)
-	parallelEngine := consensus.NewParallelEngine(log, *numThreads)
-	
+	log.Info("Running parallel processing benchmark", zap.Int("threads", *numThreads))
 	parallelStart := time.Now()
 	for i := 0; i < *iterations; i++ {
-		for _, vertex := range vertices {
-			err := parallelEngine.ProcessVertex(ctx, vertex)
-			if err != nil {
-				log.Error("Failed to process vertex: %s", err)
-			}
+		// A fresh engine per iteration, since vertices it has seen are skipped
+		parallelEngine := consensus.NewParallelEngine(log, *numThreads)
+		
+		// Submit the whole DAG so independent vertices are scheduled concurrently
+		errs := parallelEngine.ProcessVertices(ctx, vertices)
+		for vertexID, err := range errs {
+			log.Error("Failed to process vertex", zap.Stringer("vertexID", vertexID), zap.Error(err))
 		}
 	}
 	parallelDuration := time.Since(parallelStart)
-	log.Info("Parallel processing took %s", parallelDuration)
+	log.Info("Parallel processing finished", zap.Duration("duration", parallelDuration))
 	
 	// Calculate speedup
 	speedup := float64(sequentialDuration) / float64(parallelDuration)
-	log.Info("Speedup: %.2fx", speedup)
-	log.Info("Efficiency: %.2f%%", (speedup / float64(*numThreads)) * 100)
+	log.Info("Speedup", zap.Float64("speedup", speedup))
+	log.Info("Efficiency", zap.Float64("percent", (speedup/float64(*numThreads))*100))
 } 
@@ -13,6 +13,7 @@ import (
 
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/blockchain"
+	"go.uber.org/zap"
 )
 
 func main() {
@@ -20,12 +21,18 @@ func main() {
 	port := flag.Int("port", 8545, "API server port")
 	parallelism := flag.Int("parallelism", 4, "Maximum level of parallelism")
 	logLevel := flag.String("log-level", "info", "Logging level (debug, info, warn, error)")
+	pipeline := flag.Int("pipeline", 0, "Verification pipeline depth in blocks (0 disables the pipeline)")
 	flag.Parse()
 
 	// Setup logger
+	level, err := logging.ToLevel(*logLevel)
+	if err != nil {
+		fmt.Printf("Invalid log level: %s\n", err)
+		os.Exit(1)
+	}
 	logFactory := logging.NewFactory(logging.Config{
-		DisplayLevel: *logLevel,
-		LogLevel:     *logLevel,
+		DisplayLevel: level,
+		LogLevel:     level,
 	})
 	log, err := logFactory.Make("blockchain")
 	if err != nil {
@@ -37,21 +44,24 @@ func main() {
 	config := blockchain.NodeConfig{
 		MaxParallelism: *parallelism,
 		APIPort:        *port,
+		PipelineDepth:  *pipeline,
 	}
 
 	// Create and start node
 	log.Info("Starting Avalanche Parallel Blockchain node...")
 	node, err := blockchain.NewNode(log, config)
 	if err != nil {
-		log.Fatal("Failed to create node: %s", err)
+		log.Fatal("Failed to create node", zap.Error(err))
+		os.Exit(1)
 	}
 
 	if err := node.Start(); err != nil {
-		log.Fatal("Failed to start node: %s", err)
+		log.Fatal("Failed to start node", zap.Error(err))
+		os.Exit(1)
 	}
 
 	log.Info("Node started successfully")
-	log.Info("API server running on port %d", *port)
+	log.Info("API server running", zap.Int("port", *port))
 	log.Info("Press Ctrl+C to stop")
 
 	// Wait for shutdown signal
@@ -61,7 +71,7 @@ func main() {
 
 	log.Info("Shutting down...")
 	if err := node.Stop(); err != nil {
-		log.Error("Error during shutdown: %s", err)
+		log.Error("Error during shutdown", zap.Error(err))
 	}
 
 	// Give time for cleanup
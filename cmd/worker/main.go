@@ -5,65 +5,63 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/worker"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"go.uber.org/zap"
 )
 
 func main() {
-	fmt.Println("Starting Avalanche DAG worker service")
-	
-	// Setup HTTP server
-	mux := http.NewServeMux()
-	
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"healthy"}`))
-	})
-	
-	mux.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"accepted","task_id":"placeholder"}`))
+	// Parse command line flags
+	port := flag.String("port", envOrDefault("PORT", "9652"), "Worker API port")
+	numWorkers := flag.Int("workers", 4, "Number of workers")
+	checkpointDir := flag.String("checkpoint-dir", os.Getenv("CHECKPOINT_DIR"), "Directory for job checkpoints (empty disables checkpointing)")
+	segmentSize := flag.Int("segment-size", worker.DefaultSegmentSize, "Payload bytes per checkpointed segment")
+	flushInterval := flag.Int("flush-interval", worker.DefaultFlushInterval, "Completed segments per checkpoint write")
+	flag.Parse()
+
+	// Create logger
+	logFactory := logging.NewFactory(logging.Config{
+		DisplayLevel: logging.Info,
+		LogLevel:     logging.Info,
 	})
-	
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "9652"
+	log, err := logFactory.Make("worker")
+	if err != nil {
+		fmt.Printf("Failed to create logger: %s\n", err)
+		os.Exit(1)
 	}
-	
-	// Create server
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
-	}
-	
-	// Start server in goroutine so it doesn't block signal handling
-	go func() {
-		fmt.Printf("Worker service listening on port %s\n", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			fmt.Printf("Server error: %s\n", err)
+
+	options := make([]worker.ServerOption, 0, 1)
+	if *checkpointDir != "" {
+		// Jobs resubmitted after a restart resume from the checkpoints in this directory
+		store, err := worker.NewFileCheckpointStore(*checkpointDir)
+		if err != nil {
+			log.Fatal("Failed to open checkpoint store", zap.Error(err))
 			os.Exit(1)
 		}
-	}()
-	
-	// Setup signal handling
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	
-	// Wait for signal
-	<-sigs
-	fmt.Println("Shutting down worker service...")
-	
-	// Graceful shutdown with 5 second timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	if err := server.Shutdown(ctx); err != nil {
-		fmt.Printf("Server shutdown error: %s\n", err)
+		options = append(options, worker.WithCheckpointing(store, worker.CheckpointConfig{
+			SegmentSize:   *segmentSize,
+			FlushInterval: *flushInterval,
+		}))
+		log.Info("Checkpointing enabled", zap.String("dir", *checkpointDir))
+	}
+
+	// Start blocks until the server is stopped by a signal
+	server := worker.NewServer(log, ":"+*port, *numWorkers, options...)
+	if err := server.Start(context.Background()); err != nil {
+		log.Error("Worker service stopped with error", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+// envOrDefault returns the value of an environment variable, or fallback if
+// it is unset
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
-	
-	fmt.Println("Worker service stopped")
-} 
\ No newline at end of file
+	return fallback
+}
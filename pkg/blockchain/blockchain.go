@@ -5,11 +5,13 @@ package blockchain
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"go.uber.org/zap"
 )
@@ -19,7 +21,7 @@ type Blockchain struct {
 	lock          sync.RWMutex
 	logger        logging.Logger
 	genesisBlock  *Block
-	txPool        map[ids.ID]*Transaction  // Pending transactions
+	mempool       *Mempool                 // Pending transactions
 	blocks        map[ids.ID]*Block        // All blocks
 	acceptedBlocks map[ids.ID]*Block       // Accepted blocks
 	pendingBlocks map[ids.ID]*Block        // Blocks being processed
@@ -27,8 +29,28 @@ type Blockchain struct {
 	blocksByHeight map[uint64][]*Block     // Blocks organized by height
 	currentHeight uint64                   // Current blockchain height
 	maxWorkers    int                      // Maximum number of parallel workers
+	pipelineDepth int                      // Blocks verified at a time by the pipeline (0 disables it)
+	consensusDelay time.Duration           // Simulated consensus latency per block
+	head          *Block                   // Head of the canonical chain chosen by fork choice
+	headListeners []func(HeadChangedEvent) // Callbacks for canonical head changes
+	acceptedNonces map[string]uint64       // Highest accepted nonce per sender
+	offBranch     map[ids.ID]struct{}      // Off-branch pending blocks whose transactions are back in the pool
 }
 
+const (
+	// simulatedConsensusDelay is how long a block waits for simulated consensus
+	simulatedConsensusDelay = 100 * time.Millisecond
+)
+
+var (
+	// ErrParentRejected is returned for blocks built on a rejected block
+	ErrParentRejected = errors.New("parent block rejected")
+
+	// ErrNonceReused is returned for transactions whose nonce is not above
+	// the last nonce accepted for their sender
+	ErrNonceReused = errors.New("nonce already used")
+)
+
 // NewBlockchain creates a new blockchain instance
 func NewBlockchain(logger logging.Logger, maxWorkers int) (*Blockchain, error) {
 	if maxWorkers <= 0 {
@@ -37,7 +59,7 @@ func NewBlockchain(logger logging.Logger, maxWorkers int) (*Blockchain, error) {
 
 	bc := &Blockchain{
 		logger:        logger,
-		txPool:        make(map[ids.ID]*Transaction),
+		mempool:       NewMempool(DefaultMempoolSize, DefaultMaxFutureTxs),
 		blocks:        make(map[ids.ID]*Block),
 		acceptedBlocks: make(map[ids.ID]*Block),
 		pendingBlocks: make(map[ids.ID]*Block),
@@ -45,6 +67,9 @@ func NewBlockchain(logger logging.Logger, maxWorkers int) (*Blockchain, error) {
 		blocksByHeight: make(map[uint64][]*Block),
 		currentHeight: 0,
 		maxWorkers:    maxWorkers,
+		consensusDelay: simulatedConsensusDelay,
+		acceptedNonces: make(map[string]uint64),
+		offBranch:     make(map[ids.ID]struct{}),
 	}
 
 	// Create genesis block
@@ -57,10 +82,21 @@ func NewBlockchain(logger logging.Logger, maxWorkers int) (*Blockchain, error) {
 	bc.acceptedBlocks[genesis.ID()] = genesis
 	bc.latestBlocks[genesis.ID()] = genesis
 	bc.blocksByHeight[0] = []*Block{genesis}
+	bc.head = genesis
+
+	// The pool only keeps nonces the chain has not accepted yet
+	bc.mempool.SetAcceptedNonces(bc.acceptedNonce)
 
 	return bc, nil
 }
 
+// acceptedNonce returns the last nonce accepted for a sender. The caller must
+// hold the lock.
+func (bc *Blockchain) acceptedNonce(sender string) (uint64, bool) {
+	nonce, exists := bc.acceptedNonces[sender]
+	return nonce, exists
+}
+
 // AddTransaction adds a transaction to the mempool
 func (bc *Blockchain) AddTransaction(tx *Transaction) error {
 	bc.lock.Lock()
@@ -71,18 +107,39 @@ func (bc *Blockchain) AddTransaction(tx *Transaction) error {
 		return fmt.Errorf("invalid transaction: %w", err)
 	}
 
-	// Check if transaction is already in the pool
-	if _, exists := bc.txPool[tx.ID()]; exists {
-		return fmt.Errorf("transaction already in pool: %s", tx.ID())
+	// Add to pool, subject to nonce ordering, replacement and size limits
+	evicted, err := bc.mempool.Add(tx)
+	if err != nil {
+		return err
+	}
+	if evicted != nil {
+		bc.logger.Info("Evicted transaction from full pool",
+			zap.String("txID", evicted.ID().String()),
+			zap.Uint64("fee", evicted.Fee))
 	}
-
-	// Add to pool
-	bc.txPool[tx.ID()] = tx
 	bc.logger.Info("Added transaction to pool", zap.String("txID", tx.ID().String()))
 
 	return nil
 }
 
+// SetMempoolLimits sets the maximum number of pooled transactions and of
+// out-of-order transactions held per sender. Values of 0 or less restore
+// the defaults.
+func (bc *Blockchain) SetMempoolLimits(maxSize, maxFuture int) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.mempool.SetLimits(maxSize, maxFuture)
+}
+
+// GetMempoolInfo returns a summary of the transactions in the mempool
+func (bc *Blockchain) GetMempoolInfo() MempoolInfo {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.mempool.Info()
+}
+
 // CreateBlock creates a new block with transactions from the pool
 func (bc *Blockchain) CreateBlock(parentIDs []ids.ID, maxTxs int) (*Block, error) {
 	bc.lock.Lock()
@@ -104,25 +161,14 @@ func (bc *Blockchain) CreateBlock(parentIDs []ids.ID, maxTxs int) (*Block, error
 		}
 	}
 
-	// Select transactions from the pool (up to maxTxs)
-	selectedTxs := make([]*Transaction, 0, maxTxs)
-	count := 0
-	for _, tx := range bc.txPool {
-		selectedTxs = append(selectedTxs, tx)
-		delete(bc.txPool, tx.ID())
-		count++
-		if count >= maxTxs {
-			break
-		}
-	}
+	// Select transactions from the pool (up to maxTxs) by fee, in nonce order per sender
+	selectedTxs := bc.mempool.Take(maxTxs)
 
 	// Create the block
 	block, err := NewBlock(parentIDs, selectedTxs, height)
 	if err != nil {
 		// Return transactions to pool on error
-		for _, tx := range selectedTxs {
-			bc.txPool[tx.ID()] = tx
-		}
+		bc.mempool.Restore(selectedTxs)
 		return nil, fmt.Errorf("failed to create block: %w", err)
 	}
 
@@ -147,7 +193,6 @@ func (bc *Blockchain) CreateBlock(parentIDs []ids.ID, maxTxs int) (*Block, error
 // SubmitBlock submits a block for consensus
 func (bc *Blockchain) SubmitBlock(block *Block) error {
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
 
 	// Check if block already exists
 	if _, exists := bc.blocks[block.ID()]; !exists {
@@ -170,9 +215,29 @@ func (bc *Blockchain) SubmitBlock(block *Block) error {
 	}
 
 	bc.logger.Info("Submitted block for processing", zap.String("blockID", block.ID().String()))
+
+	// Re-evaluate the canonical head now that the tips have changed
+	event, changed := bc.updateHead()
+	bc.lock.Unlock()
+
+	if changed {
+		bc.notifyHeadChanged(event)
+	}
 	return nil
 }
 
+// SetVerificationPipeline enables the pipelined block processor, verifying up
+// to depth blocks at a time. A depth of 0 restores the default processor.
+func (bc *Blockchain) SetVerificationPipeline(depth int) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if depth < 0 {
+		depth = 0
+	}
+	bc.pipelineDepth = depth
+}
+
 // ProcessPendingBlocks processes blocks waiting for consensus
 func (bc *Blockchain) ProcessPendingBlocks() error {
 	bc.lock.Lock()
@@ -180,13 +245,18 @@ func (bc *Blockchain) ProcessPendingBlocks() error {
 	for _, block := range bc.pendingBlocks {
 		pendingBlocks = append(pendingBlocks, block)
 	}
+	pipelineDepth := bc.pipelineDepth
 	bc.lock.Unlock()
 
+	if pipelineDepth > 0 {
+		return bc.processPipelined(pendingBlocks, pipelineDepth)
+	}
+
 	// Process blocks in parallel
 	var wg sync.WaitGroup
 	results := make(chan struct {
-		blockID ids.ID
-		err     error
+		block *Block
+		err   error
 	}, len(pendingBlocks))
 
 	// Create a semaphore to limit concurrency
@@ -207,16 +277,13 @@ func (bc *Blockchain) ProcessPendingBlocks() error {
 			
 			if err == nil {
 				// Simulate consensus process
-				time.Sleep(100 * time.Millisecond)
-				
-				// Accept the block
-				err = b.Accept(ctx)
+				time.Sleep(bc.consensusDelay)
 			}
 
 			results <- struct {
-				blockID ids.ID
-				err     error
-			}{b.ID(), err}
+				block *Block
+				err   error
+			}{b, err}
 		}(block)
 	}
 
@@ -226,31 +293,107 @@ func (bc *Blockchain) ProcessPendingBlocks() error {
 		close(results)
 	}()
 
-	// Process results
+	// Apply the results in height order, so every block is checked against
+	// the state its parents left behind
+	verified := make([]*Block, 0, len(pendingBlocks))
+	verifyErrs := make(map[ids.ID]error, len(pendingBlocks))
+	for result := range results {
+		verified = append(verified, result.block)
+		verifyErrs[result.block.ID()] = result.err
+	}
+	sortBlocksForApplication(verified)
+
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
+	for _, block := range verified {
+		bc.applyBlock(context.Background(), block, verifyErrs[block.ID()])
+	}
 
-	for result := range results {
-		if result.err != nil {
-			bc.logger.Error("Failed to process block", 
-				zap.String("blockID", result.blockID.String()),
-				zap.Error(result.err))
-			// Could implement rejection here
-			continue
-		}
+	// Decided blocks may change the canonical head
+	event, changed := bc.updateHead()
+	bc.lock.Unlock()
 
-		// Mark as accepted
-		block := bc.blocks[result.blockID]
-		bc.acceptedBlocks[result.blockID] = block
-		delete(bc.pendingBlocks, result.blockID)
-		bc.logger.Info("Accepted block", 
-			zap.String("blockID", result.blockID.String()),
-			zap.Uint64("height", block.Height_))
+	if changed {
+		bc.notifyHeadChanged(event)
 	}
+	return nil
+}
 
+// applyBlock accepts a verified block, unless it conflicts with the state
+// left by the blocks decided before it, and rejects a block that failed
+// verification. The caller must hold the lock.
+func (bc *Blockchain) applyBlock(ctx context.Context, block *Block, verifyErr error) {
+	err := verifyErr
+	if err == nil {
+		err = bc.checkState(block)
+	}
+	if err == nil {
+		err = block.Accept(ctx)
+	}
+	if err != nil {
+		bc.rejectBlock(ctx, block, err)
+		return
+	}
+
+	for _, tx := range block.Transactions {
+		bc.acceptedNonces[tx.Sender] = tx.Nonce
+	}
+
+	// Accepted transactions are final, even if the block is off the branch
+	bc.mempool.MarkIncluded(block.Transactions)
+	bc.mempool.Settle(block.Transactions)
+	bc.acceptedBlocks[block.ID()] = block
+	delete(bc.pendingBlocks, block.ID())
+	delete(bc.offBranch, block.ID())
+	bc.logger.Info("Accepted block",
+		zap.String("blockID", block.ID().String()),
+		zap.Uint64("height", block.Height_))
+}
+
+// checkState validates a block against the decided blocks. A block built on
+// a rejected block is invalid, and so is a block with a transaction whose
+// nonce is not above the last one accepted for its sender. The caller must
+// hold the lock.
+func (bc *Blockchain) checkState(block *Block) error {
+	for _, parentID := range block.ParentIDs {
+		if parent, exists := bc.blocks[parentID]; exists && parent.Status() == choices.Rejected {
+			return fmt.Errorf("%w: %s", ErrParentRejected, parentID)
+		}
+	}
+
+	// Transactions earlier in the block count as accepted for later ones
+	nonces := make(map[string]uint64)
+	for _, tx := range block.Transactions {
+		last, known := nonces[tx.Sender]
+		if !known {
+			last, known = bc.acceptedNonces[tx.Sender]
+		}
+		if known && tx.Nonce <= last {
+			return fmt.Errorf("%w: sender %s nonce %d", ErrNonceReused, tx.Sender, tx.Nonce)
+		}
+		nonces[tx.Sender] = tx.Nonce
+	}
 	return nil
 }
 
+// rejectBlock rejects a block that failed processing and removes it and its
+// transactions from the pending blocks and the pool. The caller must hold the
+// lock.
+func (bc *Blockchain) rejectBlock(ctx context.Context, block *Block, cause error) {
+	bc.logger.Error("Failed to process block",
+		zap.String("blockID", block.ID().String()),
+		zap.Error(cause))
+
+	if err := block.Reject(ctx); err != nil {
+		bc.logger.Error("Failed to reject block",
+			zap.String("blockID", block.ID().String()),
+			zap.Error(err))
+		return
+	}
+	delete(bc.pendingBlocks, block.ID())
+	delete(bc.offBranch, block.ID())
+	bc.mempool.Discard(block.Transactions)
+}
+
 // RunConsensus runs the consensus process continuously
 func (bc *Blockchain) RunConsensus(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
@@ -286,7 +429,7 @@ func (bc *Blockchain) GetTransaction(id ids.ID) (*Transaction, error) {
 	defer bc.lock.RUnlock()
 
 	// Check in mempool first
-	if tx, exists := bc.txPool[id]; exists {
+	if tx, exists := bc.mempool.Get(id); exists {
 		return tx, nil
 	}
 
@@ -101,7 +101,7 @@ func TestAddTransaction(t *testing.T) {
 	// Add transaction to blockchain
 	err = bc.AddTransaction(tx)
 	assert.NoError(t, err)
-	assert.Len(t, bc.txPool, 1)
+	assert.Equal(t, 1, bc.mempool.Len())
 
 	// Try to add the same transaction again
 	err = bc.AddTransaction(tx)
@@ -143,7 +143,7 @@ func TestCreateBlock(t *testing.T) {
 	assert.Len(t, block.Transactions, 2)
 
 	// Verify transactions were removed from pool
-	assert.Empty(t, bc.txPool)
+	assert.Zero(t, bc.mempool.Len())
 	assert.Contains(t, bc.pendingBlocks, block.ID())
 }
 
@@ -164,7 +164,7 @@ func TestCreateBlockWithMaxTxs(t *testing.T) {
 	assert.Len(t, block.Transactions, 5)
 
 	// Verify some transactions remain in pool
-	assert.Len(t, bc.txPool, 5)
+	assert.Equal(t, 5, bc.mempool.Len())
 }
 
 func TestCreateBlockInvalidParent(t *testing.T) {
@@ -262,7 +262,7 @@ func TestConcurrentTransactions(t *testing.T) {
 	wg.Wait()
 	
 	// Verify that transactions were added (some may fail due to concurrency)
-	assert.NotEmpty(t, bc.txPool)
+	assert.NotZero(t, bc.mempool.Len())
 }
 
 func TestMultipleBlockCreation(t *testing.T) {
@@ -0,0 +1,329 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package blockchain
+
+import (
+	"bytes"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
+	"go.uber.org/zap"
+)
+
+// HeadChangedEvent describes a switch of the canonical head
+type HeadChangedEvent struct {
+	OldHead    ids.ID
+	NewHead    ids.ID
+	Height     uint64
+	ReorgDepth int      // Number of blocks abandoned from the old canonical branch
+	Abandoned  []ids.ID // Blocks that are no longer canonical
+}
+
+// OnHeadChanged registers a callback invoked whenever the canonical head
+// changes. Callbacks run after the blockchain lock has been released.
+func (bc *Blockchain) OnHeadChanged(callback func(HeadChangedEvent)) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.headListeners = append(bc.headListeners, callback)
+}
+
+// GetHead returns the head of the canonical chain
+func (bc *Blockchain) GetHead() *Block {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.head
+}
+
+// GetAcceptedFrontier returns the accepted blocks that have no accepted children
+func (bc *Blockchain) GetAcceptedFrontier() []*Block {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	hasAcceptedChild := make(map[ids.ID]bool)
+	for _, block := range bc.acceptedBlocks {
+		for _, parentID := range block.ParentIDs {
+			hasAcceptedChild[parentID] = true
+		}
+	}
+
+	frontier := make([]*Block, 0)
+	for id, block := range bc.acceptedBlocks {
+		if !hasAcceptedChild[id] {
+			frontier = append(frontier, block)
+		}
+	}
+	sortBlocksForApplication(frontier)
+	return frontier
+}
+
+// forkCandidate is a block that may become the canonical head, together
+// with the height of the highest accepted block in its ancestry
+type forkCandidate struct {
+	block          *Block
+	acceptedHeight uint64
+}
+
+// preferCandidate reports whether candidate should be the canonical head over
+// current. The consensus decision comes first: the candidate with the higher
+// accepted ancestry wins, so a taller branch of undecided blocks never
+// overrides an accepted one. Otherwise the higher block wins, and blocks at
+// the same height are ordered by the smaller block ID so every node picks the
+// same head regardless of arrival order.
+func preferCandidate(candidate, current forkCandidate) bool {
+	if current.block == nil {
+		return true
+	}
+	if candidate.acceptedHeight != current.acceptedHeight {
+		return candidate.acceptedHeight > current.acceptedHeight
+	}
+	if candidate.block.Height_ != current.block.Height_ {
+		return candidate.block.Height_ > current.block.Height_
+	}
+	return bytes.Compare(candidate.block.ID_[:], current.block.ID_[:]) < 0
+}
+
+// forkCandidates returns the blocks that may become the canonical head. The
+// walk starts at the tips and stops at accepted blocks, so it only covers the
+// undecided part of the DAG. Rejected blocks and their descendants are never
+// candidates; when a tip is ruled out, its ancestors still are unless they
+// are ruled out themselves. The caller must hold the lock.
+func (bc *Blockchain) forkCandidates() []forkCandidate {
+	type walkState struct {
+		candidate forkCandidate
+		eligible  bool
+	}
+	states := make(map[ids.ID]walkState)
+
+	var visit func(block *Block) walkState
+	visit = func(block *Block) walkState {
+		if state, seen := states[block.ID()]; seen {
+			return state
+		}
+
+		state := walkState{candidate: forkCandidate{block: block}}
+		switch block.Status() {
+		case choices.Accepted:
+			state.eligible = true
+			state.candidate.acceptedHeight = block.Height_
+		case choices.Rejected:
+			// The parents of a rejected tip may still be candidates
+			for _, parentID := range block.ParentIDs {
+				if parent, exists := bc.blocks[parentID]; exists {
+					visit(parent)
+				}
+			}
+		default:
+			state.eligible = true
+			for _, parentID := range block.ParentIDs {
+				parent, exists := bc.blocks[parentID]
+				if !exists {
+					continue
+				}
+				parentState := visit(parent)
+				if !parentState.eligible {
+					state.eligible = false
+					break
+				}
+				if parentState.candidate.acceptedHeight > state.candidate.acceptedHeight {
+					state.candidate.acceptedHeight = parentState.candidate.acceptedHeight
+				}
+			}
+		}
+		states[block.ID()] = state
+		return state
+	}
+
+	for _, tip := range bc.latestBlocks {
+		visit(tip)
+	}
+
+	candidates := make([]forkCandidate, 0, len(states))
+	for _, state := range states {
+		if state.eligible {
+			candidates = append(candidates, state.candidate)
+		}
+	}
+	return candidates
+}
+
+// updateHead applies the fork-choice rule and switches the canonical head if
+// a better candidate exists. Transactions of undecided blocks that are not on
+// the canonical branch are returned to the pool, whether they left the branch
+// in a reorganization or were built off it, and transactions of blocks that
+// join the branch are removed from the pool. The caller must hold the lock.
+func (bc *Blockchain) updateHead() (HeadChangedEvent, bool) {
+	var best forkCandidate
+	for _, candidate := range bc.forkCandidates() {
+		if preferCandidate(candidate, best) {
+			best = candidate
+		}
+	}
+
+	var (
+		event   HeadChangedEvent
+		changed bool
+	)
+	if best.block != nil && best.block != bc.head {
+		event = bc.switchHead(best.block)
+		changed = true
+	}
+
+	// Blocks can land off the branch without moving the head
+	bc.releaseOffBranch()
+	return event, changed
+}
+
+// switchHead makes block the canonical head and removes the transactions of
+// the blocks that join the canonical branch from the pool. The caller must
+// hold the lock.
+func (bc *Blockchain) switchHead(block *Block) HeadChangedEvent {
+	oldHead := bc.head
+	abandoned, adopted := bc.branchDiff(oldHead, block)
+
+	event := HeadChangedEvent{
+		NewHead: block.ID(),
+		Height:  block.Height_,
+	}
+	if oldHead != nil {
+		event.OldHead = oldHead.ID()
+	}
+	for _, abandonedBlock := range abandoned {
+		event.Abandoned = append(event.Abandoned, abandonedBlock.ID())
+	}
+	event.ReorgDepth = len(abandoned)
+
+	// Blocks only on the new branch take their transactions out of the pool
+	for _, adoptedBlock := range adopted {
+		bc.mempool.MarkIncluded(adoptedBlock.Transactions)
+		delete(bc.offBranch, adoptedBlock.ID())
+	}
+
+	bc.head = block
+	bc.logger.Info("Canonical head changed",
+		zap.String("oldHead", event.OldHead.String()),
+		zap.String("newHead", event.NewHead.String()),
+		zap.Uint64("height", event.Height),
+		zap.Int("reorgDepth", event.ReorgDepth))
+
+	return event
+}
+
+// releaseOffBranch returns the transactions of pending blocks that are not on
+// the canonical branch to the pool, once per block. Transactions that are
+// also in a block on the branch stay out of the pool, and transactions of a
+// rejected block were rejected with it. The caller must hold the lock.
+func (bc *Blockchain) releaseOffBranch() {
+	onBranch := make(map[ids.ID]bool)
+	included := make(map[ids.ID]bool)
+	for block := bc.head; block != nil && block.Status() == choices.Processing; {
+		onBranch[block.ID()] = true
+		for _, tx := range block.Transactions {
+			included[tx.ID()] = true
+		}
+
+		// Undecided blocks are extended one parent at a time
+		var parent *Block
+		for _, parentID := range block.ParentIDs {
+			if candidate, exists := bc.blocks[parentID]; exists && !onBranch[parentID] {
+				parent = candidate
+				break
+			}
+		}
+		block = parent
+	}
+
+	for id, block := range bc.pendingBlocks {
+		if onBranch[id] || block.Status() != choices.Processing {
+			continue
+		}
+		if _, released := bc.offBranch[id]; released {
+			continue
+		}
+
+		txs := make([]*Transaction, 0, len(block.Transactions))
+		for _, tx := range block.Transactions {
+			if !included[tx.ID()] {
+				txs = append(txs, tx)
+			}
+		}
+		bc.mempool.Restore(txs)
+		bc.offBranch[id] = struct{}{}
+	}
+}
+
+// notifyHeadChanged delivers a head change to the registered callbacks
+func (bc *Blockchain) notifyHeadChanged(event HeadChangedEvent) {
+	bc.lock.RLock()
+	listeners := make([]func(HeadChangedEvent), len(bc.headListeners))
+	copy(listeners, bc.headListeners)
+	bc.lock.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// branchDiff returns the blocks that are only in the ancestry of oldHead and
+// the blocks that are only in the ancestry of newHead, both in application
+// order. It walks back from both heads, highest block first, and stops as
+// soon as every block left to visit is shared by both branches, so the
+// common history below the fork point is never walked. The caller must hold
+// the lock.
+func (bc *Blockchain) branchDiff(oldHead, newHead *Block) ([]*Block, []*Block) {
+	const (
+		onOld = 1 << iota
+		onNew
+		onBoth = onOld | onNew
+	)
+	marks := make(map[ids.ID]int)
+	frontier := make(map[ids.ID]*Block)
+	add := func(block *Block, mark int) {
+		marks[block.ID()] |= mark
+		frontier[block.ID()] = block
+	}
+	if oldHead != nil {
+		add(oldHead, onOld)
+	}
+	add(newHead, onNew)
+
+	abandoned := make([]*Block, 0)
+	adopted := make([]*Block, 0)
+	for {
+		// Children are higher than their parents, so the highest block in the
+		// frontier has already received the marks of all its descendants
+		var next *Block
+		shared := true
+		for id, block := range frontier {
+			if marks[id] != onBoth {
+				shared = false
+			}
+			if next == nil || block.Height_ > next.Height_ {
+				next = block
+			}
+		}
+		if shared {
+			break
+		}
+		delete(frontier, next.ID())
+
+		mark := marks[next.ID()]
+		switch mark {
+		case onOld:
+			abandoned = append(abandoned, next)
+		case onNew:
+			adopted = append(adopted, next)
+		}
+		for _, parentID := range next.ParentIDs {
+			if parent, exists := bc.blocks[parentID]; exists {
+				add(parent, mark)
+			}
+		}
+	}
+
+	sortBlocksForApplication(abandoned)
+	sortBlocksForApplication(adopted)
+	return abandoned, adopted
+}
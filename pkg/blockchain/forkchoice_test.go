@@ -0,0 +1,251 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// submitChildBlock builds and submits a block on top of parent. Block IDs
+// only cover height, parents and tx count, so competing branches must use
+// different numbers of transactions.
+func submitChildBlock(t *testing.T, bc *Blockchain, parent *Block, numTxs int, sender string) *Block {
+	txs := make([]*Transaction, 0, numTxs)
+	for i := 0; i < numTxs; i++ {
+		tx, err := NewTransaction(sender, "recipient", 10, parent.Height_*100+uint64(i))
+		require.NoError(t, err)
+		require.NoError(t, tx.SignTransaction([]byte("key")))
+		txs = append(txs, tx)
+	}
+
+	block, err := NewBlock([]ids.ID{parent.ID()}, txs, parent.Height_+1)
+	require.NoError(t, err)
+	require.NoError(t, bc.SubmitBlock(block))
+	return block
+}
+
+func TestForkChoiceSwitchesToLongerBranch(t *testing.T) {
+	bc := createTestBlockchain(t)
+	assert.Equal(t, bc.genesisBlock.ID(), bc.GetHead().ID())
+
+	events := make([]HeadChangedEvent, 0)
+	bc.OnHeadChanged(func(event HeadChangedEvent) {
+		events = append(events, event)
+	})
+
+	// Branch A: genesis <- a1 <- a2
+	a1 := submitChildBlock(t, bc, bc.genesisBlock, 1, "alice")
+	a2 := submitChildBlock(t, bc, a1, 1, "alice")
+	assert.Equal(t, a2.ID(), bc.GetHead().ID())
+	require.Len(t, events, 2)
+
+	// Branch B: genesis <- b1 <- b2 does not overtake branch A
+	b1 := submitChildBlock(t, bc, bc.genesisBlock, 3, "bob")
+	b2 := submitChildBlock(t, bc, b1, 3, "bob")
+	require.Len(t, events, 2)
+
+	// Tie at height 2 is broken by the smaller block ID, which is a2 here
+	require.Negative(t, bytes.Compare(a2.ID_[:], b2.ID_[:]))
+	assert.Equal(t, a2.ID(), bc.GetHead().ID())
+
+	// Extending branch B past branch A reorganizes the chain
+	b3 := submitChildBlock(t, bc, b2, 3, "bob")
+	assert.Equal(t, b3.ID(), bc.GetHead().ID())
+	require.Len(t, events, 3)
+
+	event := events[2]
+	assert.Equal(t, a2.ID(), event.OldHead)
+	assert.Equal(t, b3.ID(), event.NewHead)
+	assert.Equal(t, uint64(3), event.Height)
+	assert.Equal(t, 2, event.ReorgDepth)
+	assert.Equal(t, []ids.ID{a1.ID(), a2.ID()}, event.Abandoned)
+
+	// Abandoned transactions return to the pool, canonical ones leave it
+	for _, block := range []*Block{a1, a2} {
+		for _, tx := range block.Transactions {
+			assert.True(t, bc.mempool.Has(tx.ID()))
+		}
+	}
+	for _, block := range []*Block{b1, b2, b3} {
+		for _, tx := range block.Transactions {
+			assert.False(t, bc.mempool.Has(tx.ID()))
+		}
+	}
+}
+
+func TestForkChoiceReleasesBlockCreatedOnLosingFork(t *testing.T) {
+	bc := createTestBlockchain(t)
+
+	// Two transactions keep the block ID distinct from a1 below
+	txs := make([]*Transaction, 0, 2)
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		tx, err := NewTransaction("bob", "carol", 10, nonce)
+		require.NoError(t, err)
+		require.NoError(t, tx.SignTransaction([]byte("key")))
+		require.NoError(t, bc.AddTransaction(tx))
+		txs = append(txs, tx)
+	}
+
+	block, err := bc.CreateBlock([]ids.ID{bc.genesisBlock.ID()}, 10)
+	require.NoError(t, err)
+	require.Equal(t, txs, block.Transactions)
+	assert.Zero(t, bc.mempool.Len())
+
+	// Another branch grows past the block before it is submitted, so the
+	// block never becomes the head
+	a1 := submitChildBlock(t, bc, bc.genesisBlock, 1, "alice")
+	a2 := submitChildBlock(t, bc, a1, 1, "alice")
+	require.NoError(t, bc.SubmitBlock(block))
+	assert.Equal(t, a2.ID(), bc.GetHead().ID())
+	for _, tx := range txs {
+		assert.True(t, bc.mempool.Has(tx.ID()))
+	}
+
+	// The released transactions can go into a block on the canonical branch
+	next, err := bc.CreateBlock([]ids.ID{a2.ID()}, 10)
+	require.NoError(t, err)
+	require.NoError(t, bc.SubmitBlock(next))
+	assert.Equal(t, next.ID(), bc.GetHead().ID())
+	assert.Equal(t, txs, next.Transactions)
+	assert.Zero(t, bc.mempool.Len())
+}
+
+func TestForkChoiceTieBreakIsDeterministic(t *testing.T) {
+	heads := make([]ids.ID, 0, 2)
+	for _, branchAFirst := range []bool{true, false} {
+		bc := createTestBlockchain(t)
+
+		var a, b *Block
+		if branchAFirst {
+			a = submitChildBlock(t, bc, bc.genesisBlock, 1, "alice")
+			b = submitChildBlock(t, bc, bc.genesisBlock, 3, "bob")
+		} else {
+			b = submitChildBlock(t, bc, bc.genesisBlock, 3, "bob")
+			a = submitChildBlock(t, bc, bc.genesisBlock, 1, "alice")
+		}
+
+		expected := a
+		if bytes.Compare(b.ID_[:], a.ID_[:]) < 0 {
+			expected = b
+		}
+		assert.Equal(t, expected.ID(), bc.GetHead().ID())
+		heads = append(heads, bc.GetHead().ID())
+	}
+	assert.Equal(t, heads[0], heads[1])
+}
+
+func TestForkChoiceIgnoresRejectedBlocks(t *testing.T) {
+	bc := createTestBlockchain(t)
+
+	a1 := submitChildBlock(t, bc, bc.genesisBlock, 1, "alice")
+	b1 := submitChildBlock(t, bc, bc.genesisBlock, 3, "bob")
+	require.NoError(t, b1.Reject(context.Background()))
+
+	// A rejected block is never canonical, even when it is higher
+	b2, err := NewBlock([]ids.ID{b1.ID()}, nil, 2)
+	require.NoError(t, err)
+	require.NoError(t, b2.Reject(context.Background()))
+	require.NoError(t, bc.SubmitBlock(b2))
+	assert.Equal(t, a1.ID(), bc.GetHead().ID())
+}
+
+func TestForkChoiceLeavesRejectedHeadBranch(t *testing.T) {
+	for _, depth := range []int{0, 2} {
+		t.Run(fmt.Sprintf("pipeline=%d", depth), func(t *testing.T) {
+			bc := createTestBlockchain(t)
+			bc.consensusDelay = 0
+			bc.SetVerificationPipeline(depth)
+
+			events := make([]HeadChangedEvent, 0)
+			bc.OnHeadChanged(func(event HeadChangedEvent) {
+				events = append(events, event)
+			})
+
+			// Branch A: genesis <- a1 <- a2, where every transaction fails verification
+			parent := bc.genesisBlock
+			branchA := make([]*Block, 0, 2)
+			for i := 0; i < 2; i++ {
+				tx, err := NewTransaction("alice", "recipient", 0, uint64(i))
+				require.NoError(t, err)
+				require.NoError(t, tx.SignTransaction([]byte("key")))
+
+				block, err := NewBlock([]ids.ID{parent.ID()}, []*Transaction{tx}, parent.Height_+1)
+				require.NoError(t, err)
+				require.NoError(t, bc.SubmitBlock(block))
+				branchA = append(branchA, block)
+				parent = block
+			}
+			b1 := submitChildBlock(t, bc, bc.genesisBlock, 3, "bob")
+			assert.Equal(t, branchA[1].ID(), bc.GetHead().ID())
+			require.Len(t, events, 2)
+
+			// Rejecting the head's branch moves the head to the surviving branch
+			require.NoError(t, bc.ProcessPendingBlocks())
+			assert.Equal(t, b1.ID(), bc.GetHead().ID())
+			require.Len(t, events, 3)
+
+			event := events[2]
+			assert.Equal(t, branchA[1].ID(), event.OldHead)
+			assert.Equal(t, b1.ID(), event.NewHead)
+			assert.Equal(t, 2, event.ReorgDepth)
+			assert.Equal(t, []ids.ID{branchA[0].ID(), branchA[1].ID()}, event.Abandoned)
+
+			// Rejected transactions do not return to the pool
+			for _, block := range branchA {
+				assert.Equal(t, choices.Rejected, block.Status())
+				assert.NotContains(t, bc.pendingBlocks, block.ID())
+				for _, tx := range block.Transactions {
+					assert.False(t, bc.mempool.Has(tx.ID()))
+				}
+			}
+		})
+	}
+}
+
+func TestForkChoicePrefersAcceptedBranch(t *testing.T) {
+	bc := createTestBlockchain(t)
+	bc.consensusDelay = 0
+
+	a1 := submitChildBlock(t, bc, bc.genesisBlock, 1, "alice")
+	require.NoError(t, bc.ProcessPendingBlocks())
+	require.Equal(t, choices.Accepted, a1.Status())
+	assert.Equal(t, a1.ID(), bc.GetHead().ID())
+
+	// A taller branch of undecided blocks does not override an accepted one
+	b1 := submitChildBlock(t, bc, bc.genesisBlock, 3, "bob")
+	b2 := submitChildBlock(t, bc, b1, 3, "bob")
+	assert.Equal(t, a1.ID(), bc.GetHead().ID())
+
+	// Once the taller branch is accepted, it reaches higher and wins
+	require.NoError(t, bc.ProcessPendingBlocks())
+	assert.Equal(t, b2.ID(), bc.GetHead().ID())
+}
+
+func TestGetAcceptedFrontier(t *testing.T) {
+	bc := createTestBlockchain(t)
+	bc.consensusDelay = 0
+
+	frontier := bc.GetAcceptedFrontier()
+	require.Len(t, frontier, 1)
+	assert.Equal(t, bc.genesisBlock.ID(), frontier[0].ID())
+
+	a1 := submitChildBlock(t, bc, bc.genesisBlock, 1, "alice")
+	b1 := submitChildBlock(t, bc, bc.genesisBlock, 3, "bob")
+	require.NoError(t, bc.ProcessPendingBlocks())
+
+	expected := []*Block{a1, b1}
+	sortBlocksForApplication(expected)
+	frontier = bc.GetAcceptedFrontier()
+	require.Len(t, frontier, 2)
+	assert.Equal(t, expected[0].ID(), frontier[0].ID())
+	assert.Equal(t, expected[1].ID(), frontier[1].ID())
+}
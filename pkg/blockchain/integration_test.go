@@ -198,7 +198,7 @@ func TestHighLoadTransactions(t *testing.T) {
 	blockCount := 0
 
 	// Process in batches of 10 transactions per block
-	for len(bc.txPool) > 0 {
+	for bc.mempool.Len() > 0 {
 		block, err := bc.CreateBlock(parentIDs, 10)
 		require.NoError(t, err)
 		bc.SubmitBlock(block)
@@ -221,7 +221,8 @@ func TestHighLoadTransactions(t *testing.T) {
 func createTestTransactions(t *testing.T, count int) []*Transaction {
 	transactions := make([]*Transaction, count)
 	for i := 0; i < count; i++ {
-		tx, err := NewTransaction("user"+string(rune(65+i%26)), "recipient"+string(rune(65+i%26)), uint64(100+i), uint64(i))
+		// Each of the 26 senders uses consecutive nonces so none are held back
+		tx, err := NewTransaction("user"+string(rune(65+i%26)), "recipient"+string(rune(65+i%26)), uint64(100+i), uint64(i/26))
 		require.NoError(t, err)
 		err = tx.SignTransaction([]byte("test-key"))
 		require.NoError(t, err)
@@ -263,7 +264,7 @@ func TestParallelConsensus(t *testing.T) {
 
 	// Create blocks and process
 	parentIDs := []ids.ID{bcParallel.genesisBlock.ID()}
-	for len(bcParallel.txPool) > 0 {
+	for bcParallel.mempool.Len() > 0 {
 		block, _ := bcParallel.CreateBlock(parentIDs, 20)
 		bcParallel.SubmitBlock(block)
 		bcParallel.ProcessPendingBlocks()
@@ -284,7 +285,7 @@ func TestParallelConsensus(t *testing.T) {
 
 	// Create blocks and process
 	parentIDs = []ids.ID{bcSequential.genesisBlock.ID()}
-	for len(bcSequential.txPool) > 0 {
+	for bcSequential.mempool.Len() > 0 {
 		block, _ := bcSequential.CreateBlock(parentIDs, 20)
 		bcSequential.SubmitBlock(block)
 		bcSequential.ProcessPendingBlocks()
@@ -0,0 +1,500 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package blockchain
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+const (
+	// DefaultMempoolSize is the default maximum number of pooled transactions
+	DefaultMempoolSize = 10000
+
+	// DefaultMaxFutureTxs is the default number of out-of-order transactions held per sender
+	DefaultMaxFutureTxs = 64
+)
+
+var (
+	ErrTxAlreadyInPool        = errors.New("transaction already in pool")
+	ErrNonceTooLow            = errors.New("nonce too low")
+	ErrReplacementUnderpriced = errors.New("replacement transaction underpriced")
+	ErrFutureQueueFull        = errors.New("future queue full")
+	ErrMempoolFull            = errors.New("mempool full")
+)
+
+// MempoolInfo summarizes the contents of the mempool
+type MempoolInfo struct {
+	Size     int    `json:"size"`
+	Pending  int    `json:"pending"` // Transactions ready to be included in a block
+	Future   int    `json:"future"`  // Transactions waiting for a nonce gap to be filled
+	Senders  int    `json:"senders"`
+	MaxSize  int    `json:"maxSize"`
+	MinFee   uint64 `json:"minFee"`
+	MaxFee   uint64 `json:"maxFee"`
+	Replaced uint64 `json:"replaced"` // Transactions replaced by a higher fee
+	Evicted  uint64 `json:"evicted"`  // Transactions evicted because the pool was full
+}
+
+// poolEntry is a pooled transaction and its arrival order
+type poolEntry struct {
+	tx  *Transaction
+	seq uint64
+}
+
+// senderQueue holds the pooled transactions of one sender by nonce
+type senderQueue struct {
+	sender string
+	txs    map[uint64]*poolEntry
+	next   uint64     // Next nonce expected from the sender
+	known  bool       // Whether next is known; it is once a transaction has been included
+	last   *poolEntry // Highest-nonce pooled transaction, the sender's eviction candidate
+	index  int        // Position in the eviction heap, -1 if the queue is empty
+}
+
+// split returns the sender's executable transactions, a contiguous nonce run
+// starting at the next expected nonce, and the remaining future transactions.
+// Senders without included transactions start at their lowest pooled nonce.
+func (q *senderQueue) split() (pending, future []*poolEntry) {
+	nonces := make([]uint64, 0, len(q.txs))
+	for nonce := range q.txs {
+		nonces = append(nonces, nonce)
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+	if len(nonces) == 0 {
+		return nil, nil
+	}
+
+	expected := nonces[0]
+	if q.known {
+		expected = q.next
+	}
+	for _, nonce := range nonces {
+		if nonce == expected {
+			pending = append(pending, q.txs[nonce])
+			expected++
+			continue
+		}
+		future = append(future, q.txs[nonce])
+	}
+	return pending, future
+}
+
+// Mempool holds transactions waiting to be included in a block. Transactions
+// of a sender are released in nonce order, and out-of-order transactions are
+// held in a per-sender future queue until the gap before them is filled.
+//
+// Mempool is not safe for concurrent use; Blockchain guards it with its lock.
+type Mempool struct {
+	maxSize   int
+	maxFuture int
+	txs       map[ids.ID]*poolEntry
+	senders   map[string]*senderQueue
+	tails     evictionHeap                       // Non-empty queues by the fee of their last transaction
+	accepted  func(sender string) (uint64, bool) // Last nonce the chain accepted for a sender
+	seq       uint64
+	replaced  uint64
+	evicted   uint64
+}
+
+// NewMempool creates a mempool holding at most maxSize transactions and at
+// most maxFuture out-of-order transactions per sender
+func NewMempool(maxSize, maxFuture int) *Mempool {
+	m := &Mempool{
+		txs:     make(map[ids.ID]*poolEntry),
+		senders: make(map[string]*senderQueue),
+	}
+	m.SetLimits(maxSize, maxFuture)
+	return m
+}
+
+// SetLimits changes the pool size and per-sender future queue limits. Values
+// of 0 or less restore the defaults. Transactions already pooled are kept.
+func (m *Mempool) SetLimits(maxSize, maxFuture int) {
+	if maxSize <= 0 {
+		maxSize = DefaultMempoolSize
+	}
+	if maxFuture <= 0 {
+		maxFuture = DefaultMaxFutureTxs
+	}
+	m.maxSize = maxSize
+	m.maxFuture = maxFuture
+}
+
+// SetAcceptedNonces sets the lookup for the last nonce the chain accepted for
+// a sender. The pool starts a sender's queue after that nonce, and drops
+// queues that hold nothing beyond it, so it only keeps state for senders with
+// pooled or undecided transactions. Without a lookup, the next nonce of every
+// sender with an included transaction is kept.
+func (m *Mempool) SetAcceptedNonces(lookup func(sender string) (uint64, bool)) {
+	m.accepted = lookup
+}
+
+// Add admits a transaction to the pool.
+//
+// A transaction with the same sender and nonce as a pooled one replaces it
+// only if it pays a higher fee. When the pool is full, the lowest-fee
+// transaction that is last in its sender's queue is evicted; if that is the
+// new transaction, it is rejected with ErrMempoolFull. The evicted
+// transaction, if any, is returned.
+func (m *Mempool) Add(tx *Transaction) (*Transaction, error) {
+	if _, exists := m.txs[tx.ID()]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrTxAlreadyInPool, tx.ID())
+	}
+
+	q := m.queue(tx.Sender)
+	if q.known && tx.Nonce < q.next {
+		m.prune(q)
+		return nil, fmt.Errorf("%w: expected at least %d, got %d", ErrNonceTooLow, q.next, tx.Nonce)
+	}
+
+	m.seq++
+	entry := &poolEntry{tx: tx, seq: m.seq}
+
+	// Replacement keeps the pool size unchanged
+	if existing, exists := q.txs[tx.Nonce]; exists {
+		if tx.Fee <= existing.tx.Fee {
+			return nil, fmt.Errorf("%w: fee %d does not exceed %d", ErrReplacementUnderpriced, tx.Fee, existing.tx.Fee)
+		}
+		delete(m.txs, existing.tx.ID())
+		m.insert(q, entry)
+		m.replaced++
+		return nil, nil
+	}
+
+	m.insert(q, entry)
+
+	_, future := q.split()
+	if len(future) > m.maxFuture {
+		m.remove(tx)
+		return nil, fmt.Errorf("%w: sender %s has %d queued", ErrFutureQueueFull, tx.Sender, m.maxFuture)
+	}
+
+	if len(m.txs) <= m.maxSize {
+		return nil, nil
+	}
+	victim := m.evictionCandidate()
+	m.remove(victim)
+	if victim == tx {
+		return nil, fmt.Errorf("%w: fee %d is too low", ErrMempoolFull, tx.Fee)
+	}
+	m.evicted++
+	return victim, nil
+}
+
+// Take removes up to maxTxs executable transactions from the pool, highest
+// fee first, while keeping each sender's transactions in nonce order
+func (m *Mempool) Take(maxTxs int) []*Transaction {
+	queue := make(senderHeap, 0, len(m.senders))
+	for _, q := range m.senders {
+		if pending, _ := q.split(); len(pending) > 0 {
+			queue = append(queue, &senderCursor{pending: pending})
+		}
+	}
+	heap.Init(&queue)
+
+	selected := make([]*Transaction, 0, maxTxs)
+	for len(selected) < maxTxs && queue.Len() > 0 {
+		cursor := queue[0]
+		selected = append(selected, cursor.pending[0].tx)
+		cursor.pending = cursor.pending[1:]
+		if len(cursor.pending) == 0 {
+			heap.Pop(&queue)
+		} else {
+			heap.Fix(&queue, 0)
+		}
+	}
+
+	m.MarkIncluded(selected)
+	return selected
+}
+
+// MarkIncluded removes transactions that were included in a block and
+// advances their senders' next expected nonce. Pooled transactions whose
+// nonce has been used are dropped.
+func (m *Mempool) MarkIncluded(txs []*Transaction) {
+	for _, tx := range txs {
+		if _, exists := m.txs[tx.ID()]; exists {
+			m.remove(tx)
+		}
+
+		q := m.queue(tx.Sender)
+		if !q.known || tx.Nonce >= q.next {
+			q.next = tx.Nonce + 1
+			q.known = true
+		}
+		for nonce, entry := range q.txs {
+			if nonce < q.next {
+				m.remove(entry.tx)
+			}
+		}
+		m.prune(q)
+	}
+}
+
+// Restore returns transactions to the pool, such as those of a block that was
+// abandoned. They bypass the admission rules and their nonces become
+// available again.
+func (m *Mempool) Restore(txs []*Transaction) {
+	for _, tx := range txs {
+		if _, exists := m.txs[tx.ID()]; exists {
+			continue
+		}
+
+		q := m.queue(tx.Sender)
+		if existing, exists := q.txs[tx.Nonce]; exists {
+			delete(m.txs, existing.tx.ID())
+		}
+		m.rollback(q, tx.Nonce)
+
+		m.seq++
+		m.insert(q, &poolEntry{tx: tx, seq: m.seq})
+	}
+}
+
+// Discard removes transactions that can no longer be included, such as those
+// of a rejected block. Their nonces become available again, so the sender can
+// resubmit them.
+func (m *Mempool) Discard(txs []*Transaction) {
+	for _, tx := range txs {
+		if entry, exists := m.txs[tx.ID()]; exists {
+			m.remove(entry.tx)
+		}
+		if q := m.senders[tx.Sender]; q != nil {
+			m.rollback(q, tx.Nonce)
+			m.prune(q)
+		}
+	}
+}
+
+// Settle drops the queues of the transactions' senders that no longer hold
+// anything beyond what the chain accepted. It is called once the block
+// containing the transactions has been accepted.
+func (m *Mempool) Settle(txs []*Transaction) {
+	for _, tx := range txs {
+		if q := m.senders[tx.Sender]; q != nil {
+			m.prune(q)
+		}
+	}
+}
+
+// Get returns a pooled transaction
+func (m *Mempool) Get(id ids.ID) (*Transaction, bool) {
+	entry, exists := m.txs[id]
+	if !exists {
+		return nil, false
+	}
+	return entry.tx, true
+}
+
+// Has reports whether a transaction is pooled
+func (m *Mempool) Has(id ids.ID) bool {
+	_, exists := m.txs[id]
+	return exists
+}
+
+// Len returns the number of pooled transactions
+func (m *Mempool) Len() int {
+	return len(m.txs)
+}
+
+// Info returns a summary of the pool contents
+func (m *Mempool) Info() MempoolInfo {
+	info := MempoolInfo{
+		Size:     len(m.txs),
+		MaxSize:  m.maxSize,
+		Replaced: m.replaced,
+		Evicted:  m.evicted,
+	}
+
+	for _, q := range m.senders {
+		if len(q.txs) == 0 {
+			continue
+		}
+		pending, future := q.split()
+		info.Pending += len(pending)
+		info.Future += len(future)
+		info.Senders++
+	}
+
+	first := true
+	for _, entry := range m.txs {
+		fee := entry.tx.Fee
+		if first || fee < info.MinFee {
+			info.MinFee = fee
+		}
+		if first || fee > info.MaxFee {
+			info.MaxFee = fee
+		}
+		first = false
+	}
+	return info
+}
+
+// evictionCandidate returns the lowest-fee transaction among those last in
+// their sender's queue, so eviction never opens a nonce gap. Ties evict the
+// most recent arrival.
+func (m *Mempool) evictionCandidate() *Transaction {
+	return m.tails[0].last.tx
+}
+
+// queue returns the queue of a sender, creating it if needed. A new queue
+// expects the nonce after the last one the chain accepted for the sender.
+func (m *Mempool) queue(sender string) *senderQueue {
+	if q, exists := m.senders[sender]; exists {
+		return q
+	}
+
+	q := &senderQueue{sender: sender, txs: make(map[uint64]*poolEntry), index: -1}
+	if m.accepted != nil {
+		if nonce, ok := m.accepted(sender); ok {
+			q.next = nonce + 1
+			q.known = true
+		}
+	}
+	m.senders[sender] = q
+	return q
+}
+
+// insert pools an entry, replacing any entry with the same nonce
+func (m *Mempool) insert(q *senderQueue, entry *poolEntry) {
+	q.txs[entry.tx.Nonce] = entry
+	m.txs[entry.tx.ID()] = entry
+	m.updateTail(q)
+}
+
+// remove deletes a pooled transaction
+func (m *Mempool) remove(tx *Transaction) {
+	delete(m.txs, tx.ID())
+	q := m.senders[tx.Sender]
+	if entry, exists := q.txs[tx.Nonce]; exists && entry.tx == tx {
+		delete(q.txs, tx.Nonce)
+		m.updateTail(q)
+	}
+	m.prune(q)
+}
+
+// rollback makes a nonce available again, but never one the chain accepted
+func (m *Mempool) rollback(q *senderQueue, nonce uint64) {
+	if !q.known || nonce >= q.next {
+		return
+	}
+	if m.accepted != nil {
+		if accepted, ok := m.accepted(q.sender); ok && nonce <= accepted {
+			nonce = accepted + 1
+		}
+	}
+	if nonce < q.next {
+		q.next = nonce
+	}
+}
+
+// prune drops an empty queue whose next nonce a new queue would start at
+// anyway, either because no transaction was included or because the chain
+// accepted every included one
+func (m *Mempool) prune(q *senderQueue) {
+	if len(q.txs) > 0 {
+		return
+	}
+	if q.known {
+		if m.accepted == nil {
+			return
+		}
+		accepted, ok := m.accepted(q.sender)
+		if !ok || accepted+1 != q.next {
+			return
+		}
+	}
+	delete(m.senders, q.sender)
+}
+
+// updateTail recomputes the last transaction of a queue and its place in the
+// eviction heap
+func (m *Mempool) updateTail(q *senderQueue) {
+	q.last = nil
+	for _, entry := range q.txs {
+		if q.last == nil || entry.tx.Nonce > q.last.tx.Nonce {
+			q.last = entry
+		}
+	}
+
+	switch {
+	case q.last == nil && q.index >= 0:
+		heap.Remove(&m.tails, q.index)
+	case q.last != nil && q.index < 0:
+		heap.Push(&m.tails, q)
+	case q.last != nil:
+		heap.Fix(&m.tails, q.index)
+	}
+}
+
+// senderCursor walks the executable transactions of one sender
+type senderCursor struct {
+	pending []*poolEntry
+}
+
+// senderHeap orders senders by the fee of their next executable transaction,
+// then by its arrival
+type senderHeap []*senderCursor
+
+func (h senderHeap) Len() int { return len(h) }
+
+func (h senderHeap) Less(i, j int) bool {
+	a, b := h[i].pending[0], h[j].pending[0]
+	if a.tx.Fee != b.tx.Fee {
+		return a.tx.Fee > b.tx.Fee
+	}
+	return a.seq < b.seq
+}
+
+func (h senderHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *senderHeap) Push(x interface{}) { *h = append(*h, x.(*senderCursor)) }
+
+func (h *senderHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	cursor := old[n-1]
+	*h = old[:n-1]
+	return cursor
+}
+
+// evictionHeap orders non-empty sender queues by the fee of their last
+// transaction, lowest first, then by its arrival, most recent first
+type evictionHeap []*senderQueue
+
+func (h evictionHeap) Len() int { return len(h) }
+
+func (h evictionHeap) Less(i, j int) bool {
+	a, b := h[i].last, h[j].last
+	if a.tx.Fee != b.tx.Fee {
+		return a.tx.Fee < b.tx.Fee
+	}
+	return a.seq > b.seq
+}
+
+func (h evictionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *evictionHeap) Push(x interface{}) {
+	q := x.(*senderQueue)
+	q.index = len(*h)
+	*h = append(*h, q)
+}
+
+func (h *evictionHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	q := old[n-1]
+	q.index = -1
+	*h = old[:n-1]
+	return q
+}
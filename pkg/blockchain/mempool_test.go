@@ -0,0 +1,272 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFeeTx(t *testing.T, sender string, nonce, fee uint64) *Transaction {
+	tx, err := NewTransactionWithFee(sender, "recipient", 10, nonce, fee)
+	require.NoError(t, err)
+	return tx
+}
+
+// nonces returns the sender and nonce of each transaction in order
+func nonces(txs []*Transaction) []string {
+	out := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		out = append(out, tx.Sender+"-"+string(rune('0'+tx.Nonce)))
+	}
+	return out
+}
+
+func TestMempoolOutOfOrderArrival(t *testing.T) {
+	m := NewMempool(0, 0)
+
+	// Nonce 0 is included, so alice's next expected nonce is 1
+	_, err := m.Add(newFeeTx(t, "alice", 0, 1))
+	require.NoError(t, err)
+	require.Len(t, m.Take(10), 1)
+
+	_, err = m.Add(newFeeTx(t, "alice", 3, 1))
+	require.NoError(t, err)
+	_, err = m.Add(newFeeTx(t, "alice", 2, 1))
+	require.NoError(t, err)
+
+	// Both are held in the future queue until the gap is filled
+	info := m.Info()
+	assert.Equal(t, 0, info.Pending)
+	assert.Equal(t, 2, info.Future)
+	assert.Empty(t, m.Take(10))
+
+	_, err = m.Add(newFeeTx(t, "alice", 1, 1))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice-1", "alice-2", "alice-3"}, nonces(m.Take(10)))
+
+	// Used nonces are rejected
+	_, err = m.Add(newFeeTx(t, "alice", 2, 5))
+	assert.ErrorIs(t, err, ErrNonceTooLow)
+}
+
+func TestMempoolFutureQueueLimit(t *testing.T) {
+	m := NewMempool(0, 2)
+
+	for _, nonce := range []uint64{0, 2, 3} {
+		_, err := m.Add(newFeeTx(t, "alice", nonce, 1))
+		require.NoError(t, err)
+	}
+	_, err := m.Add(newFeeTx(t, "alice", 4, 1))
+	assert.ErrorIs(t, err, ErrFutureQueueFull)
+	assert.Equal(t, 3, m.Len())
+
+	// Filling the gap makes room again
+	_, err = m.Add(newFeeTx(t, "alice", 1, 1))
+	require.NoError(t, err)
+	_, err = m.Add(newFeeTx(t, "alice", 4, 1))
+	assert.NoError(t, err)
+}
+
+func TestMempoolReplacement(t *testing.T) {
+	m := NewMempool(0, 0)
+
+	original := newFeeTx(t, "alice", 0, 5)
+	_, err := m.Add(original)
+	require.NoError(t, err)
+
+	_, err = m.Add(original)
+	assert.ErrorIs(t, err, ErrTxAlreadyInPool)
+
+	// An equal fee does not replace the pooled transaction
+	sameFee, err := NewTransactionWithFee("alice", "someone-else", 10, 0, 5)
+	require.NoError(t, err)
+	_, err = m.Add(sameFee)
+	assert.ErrorIs(t, err, ErrReplacementUnderpriced)
+	assert.True(t, m.Has(original.ID()))
+
+	replacement := newFeeTx(t, "alice", 0, 6)
+	_, err = m.Add(replacement)
+	require.NoError(t, err)
+	assert.False(t, m.Has(original.ID()))
+	assert.True(t, m.Has(replacement.ID()))
+	assert.Equal(t, 1, m.Len())
+	assert.Equal(t, uint64(1), m.Info().Replaced)
+}
+
+func TestMempoolEvictsLowestFee(t *testing.T) {
+	m := NewMempool(3, 0)
+
+	cheap := newFeeTx(t, "bob", 0, 1)
+	for _, tx := range []*Transaction{newFeeTx(t, "alice", 0, 1), newFeeTx(t, "alice", 1, 9), cheap} {
+		_, err := m.Add(tx)
+		require.NoError(t, err)
+	}
+
+	// alice-0 has the same fee as bob-0 but is not last in its queue, so
+	// evicting it would strand alice-1
+	evicted, err := m.Add(newFeeTx(t, "carol", 0, 5))
+	require.NoError(t, err)
+	assert.Equal(t, cheap, evicted)
+	assert.Equal(t, 3, m.Len())
+
+	// A transaction paying less than everything pooled is rejected outright
+	rejected := newFeeTx(t, "dave", 0, 0)
+	_, err = m.Add(rejected)
+	assert.ErrorIs(t, err, ErrMempoolFull)
+	assert.False(t, m.Has(rejected.ID()))
+
+	info := m.Info()
+	assert.Equal(t, uint64(1), info.Evicted)
+	assert.Equal(t, uint64(1), info.MinFee)
+	assert.Equal(t, uint64(9), info.MaxFee)
+}
+
+func TestMempoolEvictionMatchesScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	m := NewMempool(0, 0)
+
+	// scan is the lowest-fee, most recent transaction last in its queue
+	scan := func() *Transaction {
+		var victim *poolEntry
+		for _, q := range m.senders {
+			var last *poolEntry
+			for _, entry := range q.txs {
+				if last == nil || entry.tx.Nonce > last.tx.Nonce {
+					last = entry
+				}
+			}
+			if last != nil && (victim == nil || last.tx.Fee < victim.tx.Fee ||
+				(last.tx.Fee == victim.tx.Fee && last.seq > victim.seq)) {
+				victim = last
+			}
+		}
+		return victim.tx
+	}
+
+	for i := 0; i < 500; i++ {
+		sender := fmt.Sprintf("user%d", rng.Intn(20))
+		nonce := uint64(rng.Intn(8))
+		fee := uint64(rng.Intn(10))
+		switch rng.Intn(4) {
+		case 0:
+			m.Take(1 + rng.Intn(3))
+		case 1:
+			m.Discard([]*Transaction{newFeeTx(t, sender, nonce, fee)})
+		default:
+			_, _ = m.Add(newFeeTx(t, sender, nonce, fee))
+		}
+		if m.Len() > 0 {
+			require.Equal(t, scan(), m.evictionCandidate(), "step %d", i)
+		}
+	}
+}
+
+func TestMempoolDiscardReleasesNonces(t *testing.T) {
+	m := NewMempool(0, 0)
+
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		_, err := m.Add(newFeeTx(t, "alice", nonce, 1))
+		require.NoError(t, err)
+	}
+	taken := m.Take(10)
+	require.Len(t, taken, 3)
+
+	// The block holding alice-1 and alice-2 is rejected, alice-0 stays used
+	m.Discard(taken[1:])
+	_, err := m.Add(newFeeTx(t, "alice", 0, 5))
+	assert.ErrorIs(t, err, ErrNonceTooLow)
+
+	resubmitted := newFeeTx(t, "alice", 1, 5)
+	_, err = m.Add(resubmitted)
+	require.NoError(t, err)
+	_, err = m.Add(newFeeTx(t, "alice", 2, 5))
+	require.NoError(t, err)
+	assert.Equal(t, 2, m.Info().Pending)
+	assert.Equal(t, []string{"alice-1", "alice-2"}, nonces(m.Take(10)))
+}
+
+func TestMempoolPrunesAcceptedSenders(t *testing.T) {
+	m := NewMempool(0, 0)
+	accepted := make(map[string]uint64)
+	m.SetAcceptedNonces(func(sender string) (uint64, bool) {
+		nonce, exists := accepted[sender]
+		return nonce, exists
+	})
+
+	for i := 0; i < 100; i++ {
+		_, err := m.Add(newFeeTx(t, fmt.Sprintf("user%d", i), 0, 1))
+		require.NoError(t, err)
+	}
+	taken := m.Take(100)
+	require.Len(t, taken, 100)
+
+	// Included but undecided nonces are tracked by the pool
+	assert.Len(t, m.senders, 100)
+	_, err := m.Add(newFeeTx(t, "user0", 0, 5))
+	assert.ErrorIs(t, err, ErrNonceTooLow)
+
+	// Once accepted, the chain records them and the queues are dropped
+	for _, tx := range taken {
+		accepted[tx.Sender] = tx.Nonce
+	}
+	m.Settle(taken)
+	assert.Empty(t, m.senders)
+
+	_, err = m.Add(newFeeTx(t, "user0", 0, 5))
+	assert.ErrorIs(t, err, ErrNonceTooLow)
+	assert.Empty(t, m.senders)
+
+	// A rejected block cannot release a nonce the chain accepted
+	next := newFeeTx(t, "user0", 1, 1)
+	_, err = m.Add(next)
+	require.NoError(t, err)
+	require.Len(t, m.Take(1), 1)
+	m.Discard([]*Transaction{taken[0], next})
+	assert.Empty(t, m.senders)
+	_, err = m.Add(newFeeTx(t, "user0", 1, 1))
+	assert.NoError(t, err)
+}
+
+func TestMempoolTakeByFeeInNonceOrder(t *testing.T) {
+	m := NewMempool(0, 0)
+
+	// alice's high-fee transaction sits behind a low-fee one
+	for _, tx := range []*Transaction{
+		newFeeTx(t, "alice", 0, 1),
+		newFeeTx(t, "alice", 1, 10),
+		newFeeTx(t, "bob", 0, 5),
+		newFeeTx(t, "carol", 0, 3),
+	} {
+		_, err := m.Add(tx)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"bob-0", "carol-0", "alice-0"}, nonces(m.Take(3)))
+	assert.Equal(t, []string{"alice-1"}, nonces(m.Take(3)))
+}
+
+func TestCreateBlockUsesMempoolOrder(t *testing.T) {
+	bc := createTestBlockchain(t)
+	bc.SetMempoolLimits(2, 0)
+
+	require.NoError(t, bc.AddTransaction(newFeeTx(t, "alice", 1, 1)))
+	require.NoError(t, bc.AddTransaction(newFeeTx(t, "bob", 7, 4)))
+	require.NoError(t, bc.AddTransaction(newFeeTx(t, "carol", 0, 2)))
+
+	info := bc.GetMempoolInfo()
+	assert.Equal(t, 2, info.Size)
+	assert.Equal(t, 2, info.MaxSize)
+	assert.Equal(t, uint64(1), info.Evicted)
+
+	block, err := bc.CreateBlock([]ids.ID{bc.genesisBlock.ID()}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob-7", "carol-0"}, nonces(block.Transactions))
+	assert.Zero(t, bc.GetMempoolInfo().Size)
+}
@@ -6,11 +6,13 @@ package blockchain
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/shutdown"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"go.uber.org/zap"
@@ -22,12 +24,18 @@ const (
 	
 	// DefaultAPIPort is the default port for the HTTP API
 	DefaultAPIPort = 8545
+
+	// ShutdownTimeout bounds how long Stop waits for requests and background loops
+	ShutdownTimeout = 5 * time.Second
 )
 
 // NodeConfig contains configuration for a blockchain node
 type NodeConfig struct {
 	MaxParallelism int    // Maximum number of parallel processors
 	APIPort        int    // HTTP API port
+	PipelineDepth  int    // Verification pipeline depth (0 disables the pipeline)
+	MempoolSize    int    // Maximum number of pooled transactions (0 uses the default)
+	MaxFutureTxs   int    // Out-of-order transactions held per sender (0 uses the default)
 }
 
 // Node represents a blockchain node with HTTP API
@@ -38,7 +46,9 @@ type Node struct {
 	server     *http.Server
 	config     NodeConfig
 	running    bool
-	shutdownCtxCancel context.CancelFunc
+	loops      *shutdown.Group // Background loops stopped by Stop
+
+	shutdownTimeout time.Duration // Bounds each stage of Stop
 }
 
 // NewNode creates a new blockchain node
@@ -48,6 +58,8 @@ func NewNode(logger logging.Logger, config NodeConfig) (*Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create blockchain: %w", err)
 	}
+	blockchain.SetVerificationPipeline(config.PipelineDepth)
+	blockchain.SetMempoolLimits(config.MempoolSize, config.MaxFutureTxs)
 
 	// Create node
 	node := &Node{
@@ -55,6 +67,8 @@ func NewNode(logger logging.Logger, config NodeConfig) (*Node, error) {
 		blockchain: blockchain,
 		config:     config,
 		running:    false,
+
+		shutdownTimeout: ShutdownTimeout,
 	}
 
 	return node, nil
@@ -70,9 +84,10 @@ func (n *Node) Start() error {
 	}
 
 	// Start blockchain consensus
-	ctx, cancel := context.WithCancel(context.Background())
-	go n.blockchain.RunConsensus(ctx, 500*time.Millisecond)
-	n.shutdownCtxCancel = cancel  // Store the cancel function for later use
+	n.loops = shutdown.NewGroup(context.Background())
+	n.loops.Go(func(ctx context.Context) {
+		n.blockchain.RunConsensus(ctx, 500*time.Millisecond)
+	})
 
 	// Setup HTTP API server
 	mux := http.NewServeMux()
@@ -82,6 +97,9 @@ func (n *Node) Start() error {
 	mux.HandleFunc("/block/get", n.handleGetBlock)
 	mux.HandleFunc("/blockchain/height", n.handleGetBlockchainHeight)
 	mux.HandleFunc("/blockchain/latest", n.handleGetLatestBlocks)
+	mux.HandleFunc("/blockchain/head", n.handleGetHead)
+	mux.HandleFunc("/blockchain/tips", n.handleGetTips)
+	mux.HandleFunc("/mempool/info", n.handleGetMempoolInfo)
 
 	// Create server
 	n.server = &http.Server{
@@ -111,14 +129,25 @@ func (n *Node) Stop() error {
 	}
 
 	// Shutdown server
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), n.shutdownTimeout)
 	defer cancel()
-	
+
+	// Consensus is stopped even if the server fails to shut down, so the node
+	// is never left half running
+	var errs []error
 	if err := n.server.Shutdown(ctx); err != nil {
-		return fmt.Errorf("server shutdown error: %w", err)
+		errs = append(errs, fmt.Errorf("server shutdown error: %w", err))
+	}
+
+	// Stop consensus, letting a round in progress finish
+	if err := n.loops.Shutdown(n.shutdownTimeout); err != nil {
+		errs = append(errs, fmt.Errorf("consensus shutdown error: %w", err))
 	}
 
 	n.running = false
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
 	n.logger.Info("Blockchain node stopped")
 	return nil
 }
@@ -135,6 +164,7 @@ func (n *Node) handleSubmitTransaction(w http.ResponseWriter, r *http.Request) {
 		Recipient string `json:"recipient"`
 		Amount    uint64 `json:"amount"`
 		Nonce     uint64 `json:"nonce"`
+		Fee       uint64 `json:"fee"`
 		Key       string `json:"key"` // Simplified key for signing
 	}
 
@@ -145,7 +175,7 @@ func (n *Node) handleSubmitTransaction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create transaction
-	tx, err := NewTransaction(req.Sender, req.Recipient, req.Amount, req.Nonce)
+	tx, err := NewTransactionWithFee(req.Sender, req.Recipient, req.Amount, req.Nonce, req.Fee)
 	if err != nil {
 		http.Error(w, "Failed to create transaction: "+err.Error(), http.StatusBadRequest)
 		return
@@ -208,6 +238,7 @@ func (n *Node) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
 		Recipient string `json:"recipient"`
 		Amount    uint64 `json:"amount"`
 		Nonce     uint64 `json:"nonce"`
+		Fee       uint64 `json:"fee"`
 		Status    string `json:"status"`
 	}{
 		ID:        tx.ID().String(),
@@ -215,6 +246,7 @@ func (n *Node) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
 		Recipient: tx.Recipient,
 		Amount:    tx.Amount,
 		Nonce:     tx.Nonce,
+		Fee:       tx.Fee,
 		Status:    tx.Status().String(),
 	}
 
@@ -400,4 +432,82 @@ func (n *Node) handleGetLatestBlocks(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
+}
+
+// blockSummary is the response format for a block reference
+type blockSummary struct {
+	ID     string `json:"id"`
+	Height uint64 `json:"height"`
+	Status string `json:"status"`
+}
+
+// summarizeBlocks converts blocks to their response format
+func summarizeBlocks(blocks []*Block) []blockSummary {
+	summaries := make([]blockSummary, 0, len(blocks))
+	for _, block := range blocks {
+		summaries = append(summaries, blockSummary{
+			ID:     block.ID().String(),
+			Height: block.Height_,
+			Status: block.Status().String(),
+		})
+	}
+	return summaries
+}
+
+// handleGetHead handles requests for the canonical head
+func (n *Node) handleGetHead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	head := n.blockchain.GetHead()
+
+	// Return canonical head
+	response := struct {
+		Head blockSummary `json:"head"`
+	}{
+		Head: summarizeBlocks([]*Block{head})[0],
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetTips handles requests for the canonical head, all tips and the accepted frontier
+func (n *Node) handleGetTips(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	head := n.blockchain.GetHead()
+	tips := n.blockchain.GetLatestBlocks()
+	sortBlocksForApplication(tips)
+	frontier := n.blockchain.GetAcceptedFrontier()
+
+	// Return fork-choice view
+	response := struct {
+		Head             blockSummary   `json:"head"`
+		Tips             []blockSummary `json:"tips"`
+		AcceptedFrontier []blockSummary `json:"acceptedFrontier"`
+	}{
+		Head:             summarizeBlocks([]*Block{head})[0],
+		Tips:             summarizeBlocks(tips),
+		AcceptedFrontier: summarizeBlocks(frontier),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetMempoolInfo handles mempool statistics API
+func (n *Node) handleGetMempoolInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.blockchain.GetMempoolInfo())
 } 
\ No newline at end of file
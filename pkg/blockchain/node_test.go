@@ -0,0 +1,63 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeStopWaitsForConsensusLoop(t *testing.T) {
+	node, err := NewNode(&testLogger{}, NodeConfig{MaxParallelism: 2, APIPort: 0})
+	require.NoError(t, err)
+
+	require.NoError(t, node.Start())
+	require.NoError(t, node.Stop())
+
+	// The consensus loop has been cancelled and has already returned
+	assert.ErrorIs(t, node.loops.Context().Err(), context.Canceled)
+	assert.NoError(t, node.loops.Wait(time.Millisecond))
+
+	assert.Error(t, node.Stop())
+}
+
+func TestNodeStopsConsensusWhenServerShutdownFails(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	node, err := NewNode(&testLogger{}, NodeConfig{MaxParallelism: 2, APIPort: port})
+	require.NoError(t, err)
+	node.shutdownTimeout = 50 * time.Millisecond
+	require.NoError(t, node.Start())
+
+	// A request that never completes keeps the server from shutting down
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+	defer conn.Close()
+	_, err = conn.Write([]byte("POST /transaction/submit HTTP/1.1\r\nHost: node\r\n"))
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond) // Let the server read the partial request
+
+	err = node.Stop()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The consensus loop was stopped regardless and the node can start again
+	assert.ErrorIs(t, node.loops.Context().Err(), context.Canceled)
+	assert.NoError(t, node.loops.Wait(time.Millisecond))
+	assert.False(t, node.running)
+	require.NoError(t, conn.Close())
+	require.NoError(t, node.Start())
+	require.NoError(t, node.Stop())
+}
@@ -0,0 +1,241 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildRandomWorkload submits a randomized DAG of blocks, some containing
+// invalid transactions, and returns them in submission order. The same seed
+// always produces the same workload.
+func buildRandomWorkload(t *testing.T, bc *Blockchain, seed int64) []*Block {
+	rng := rand.New(rand.NewSource(seed))
+	parents := []*Block{bc.genesisBlock}
+	blocks := make([]*Block, 0)
+
+	numBlocks := 5 + rng.Intn(20)
+	for i := 0; i < numBlocks; i++ {
+		parent := parents[rng.Intn(len(parents))]
+
+		numTxs := rng.Intn(8)
+		txs := make([]*Transaction, 0, numTxs)
+		for j := 0; j < numTxs; j++ {
+			sender := fmt.Sprintf("user%d", rng.Intn(10))
+			amount := uint64(1 + rng.Intn(1000))
+			if rng.Intn(10) == 0 {
+				amount = 0 // Fails stateless verification
+			}
+			tx, err := NewTransaction(sender, "recipient", amount, uint64(i*100+j))
+			require.NoError(t, err)
+			require.NoError(t, tx.SignTransaction([]byte("key")))
+			txs = append(txs, tx)
+		}
+
+		block, err := NewBlock([]ids.ID{parent.ID()}, txs, parent.Height_+1)
+		require.NoError(t, err)
+		if _, exists := bc.blocks[block.ID()]; exists {
+			// Block IDs only cover height, parents and tx count
+			continue
+		}
+		require.NoError(t, bc.SubmitBlock(block))
+
+		parents = append(parents, block)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func TestPipelineMatchesSequentialProcessing(t *testing.T) {
+	for seed := int64(0); seed < 25; seed++ {
+		depth := 1 + int(seed%4)
+		workers := 1 + int(seed%3)
+
+		t.Run(fmt.Sprintf("seed=%d/depth=%d/workers=%d", seed, depth, workers), func(t *testing.T) {
+			sequential, err := NewBlockchain(&testLogger{}, workers)
+			require.NoError(t, err)
+			sequential.consensusDelay = 0
+
+			pipelined, err := NewBlockchain(&testLogger{}, workers)
+			require.NoError(t, err)
+			pipelined.consensusDelay = 0
+			pipelined.SetVerificationPipeline(depth)
+
+			expected := buildRandomWorkload(t, sequential, seed)
+			actual := buildRandomWorkload(t, pipelined, seed)
+			require.Len(t, actual, len(expected))
+
+			require.NoError(t, sequential.ProcessPendingBlocks())
+			require.NoError(t, pipelined.ProcessPendingBlocks())
+
+			for i := range expected {
+				assert.Equal(t, expected[i].ID(), actual[i].ID())
+				assert.Equal(t, expected[i].Status(), actual[i].Status(), "block %d", i)
+				for j := range expected[i].Transactions {
+					assert.Equal(t, expected[i].Transactions[j].Status(), actual[i].Transactions[j].Status(), "block %d tx %d", i, j)
+				}
+			}
+
+			assert.Equal(t, blockIDs(sequential.acceptedBlocks), blockIDs(pipelined.acceptedBlocks))
+			assert.Equal(t, blockIDs(sequential.pendingBlocks), blockIDs(pipelined.pendingBlocks))
+		})
+	}
+}
+
+func TestPipelineAppliesInHeightOrder(t *testing.T) {
+	bc := createTestBlockchain(t)
+	bc.consensusDelay = 0
+	bc.SetVerificationPipeline(2)
+
+	blocks := buildRandomWorkload(t, bc, 42)
+	pending := make([]*Block, len(blocks))
+	copy(pending, blocks)
+	sortBlocksForApplication(pending)
+
+	for i := 1; i < len(pending); i++ {
+		assert.LessOrEqual(t, pending[i-1].Height_, pending[i].Height_)
+	}
+
+	require.NoError(t, bc.ProcessPendingBlocks())
+	for _, block := range blocks {
+		if block.Status().String() == "Accepted" {
+			assert.Contains(t, bc.acceptedBlocks, block.ID())
+			assert.NotContains(t, bc.pendingBlocks, block.ID())
+		} else {
+			assert.Equal(t, "Rejected", block.Status().String())
+			assert.NotContains(t, bc.pendingBlocks, block.ID())
+		}
+	}
+}
+
+// buildChain submits a linear chain of blocks on top of genesis, each with a
+// single valid transaction
+func buildChain(t *testing.T, bc *Blockchain, length int) []*Block {
+	parent := bc.genesisBlock
+	blocks := make([]*Block, 0, length)
+	for i := 0; i < length; i++ {
+		tx, err := NewTransaction("alice", "bob", 10, uint64(i+1))
+		require.NoError(t, err)
+		require.NoError(t, tx.SignTransaction([]byte("key")))
+
+		block, err := NewBlock([]ids.ID{parent.ID()}, []*Transaction{tx}, parent.Height_+1)
+		require.NoError(t, err)
+		require.NoError(t, bc.SubmitBlock(block))
+
+		parent = block
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func TestPipelineOverlapsConsensusWait(t *testing.T) {
+	const (
+		numBlocks = 12
+		depth     = 2
+		workers   = 2
+		delay     = 40 * time.Millisecond
+	)
+
+	sequential, err := NewBlockchain(&testLogger{}, workers)
+	require.NoError(t, err)
+	sequential.consensusDelay = delay
+
+	pipelined, err := NewBlockchain(&testLogger{}, workers)
+	require.NoError(t, err)
+	pipelined.consensusDelay = delay
+	pipelined.SetVerificationPipeline(depth)
+
+	expected := buildChain(t, sequential, numBlocks)
+	actual := buildChain(t, pipelined, numBlocks)
+
+	start := time.Now()
+	require.NoError(t, sequential.ProcessPendingBlocks())
+	sequentialTime := time.Since(start)
+
+	start = time.Now()
+	require.NoError(t, pipelined.ProcessPendingBlocks())
+	pipelinedTime := time.Since(start)
+
+	// The default processor holds a worker for each block's consensus wait,
+	// so it takes numBlocks/workers delays. The pipeline only bounds
+	// verification and waits for all blocks at once.
+	t.Logf("sequential %v, pipelined %v", sequentialTime, pipelinedTime)
+	assert.GreaterOrEqual(t, sequentialTime, numBlocks/workers*delay)
+	assert.Less(t, 2*pipelinedTime, sequentialTime)
+
+	for i := range expected {
+		assert.Equal(t, "Accepted", expected[i].Status().String())
+		assert.Equal(t, "Accepted", actual[i].Status().String())
+	}
+	assert.Equal(t, expected[numBlocks-1].ID(), sequential.GetHead().ID())
+	assert.Equal(t, actual[numBlocks-1].ID(), pipelined.GetHead().ID())
+}
+
+func TestPipelineRejectsReusedNonce(t *testing.T) {
+	bc := createTestBlockchain(t)
+	bc.consensusDelay = 0
+	bc.SetVerificationPipeline(2)
+
+	blocks := buildChain(t, bc, 2)
+	require.NoError(t, bc.ProcessPendingBlocks())
+
+	// Replays the first block's transaction on top of the chain
+	tx, err := NewTransaction("alice", "bob", 10, 1)
+	require.NoError(t, err)
+	require.NoError(t, tx.SignTransaction([]byte("key")))
+	replay, err := NewBlock([]ids.ID{blocks[1].ID()}, []*Transaction{tx}, blocks[1].Height_+1)
+	require.NoError(t, err)
+	require.NoError(t, bc.SubmitBlock(replay))
+
+	bc.lock.Lock()
+	assert.ErrorIs(t, bc.checkState(replay), ErrNonceReused)
+	bc.lock.Unlock()
+
+	require.NoError(t, bc.ProcessPendingBlocks())
+	assert.Equal(t, "Rejected", replay.Status().String())
+	assert.NotContains(t, bc.acceptedBlocks, replay.ID())
+	assert.Equal(t, blocks[1].ID(), bc.GetHead().ID())
+}
+
+func TestPipelineRejectsChildOfRejectedBlock(t *testing.T) {
+	bc := createTestBlockchain(t)
+	bc.consensusDelay = 0
+	bc.SetVerificationPipeline(2)
+
+	// The parent fails verification, the child is valid on its own
+	invalid, err := NewTransaction("alice", "bob", 0, 1)
+	require.NoError(t, err)
+	require.NoError(t, invalid.SignTransaction([]byte("key")))
+	parent, err := NewBlock([]ids.ID{bc.genesisBlock.ID()}, []*Transaction{invalid}, 1)
+	require.NoError(t, err)
+	require.NoError(t, bc.SubmitBlock(parent))
+
+	valid, err := NewTransaction("alice", "bob", 10, 2)
+	require.NoError(t, err)
+	require.NoError(t, valid.SignTransaction([]byte("key")))
+	child, err := NewBlock([]ids.ID{parent.ID()}, []*Transaction{valid}, 2)
+	require.NoError(t, err)
+	require.NoError(t, bc.SubmitBlock(child))
+
+	require.NoError(t, bc.ProcessPendingBlocks())
+	assert.Equal(t, "Rejected", parent.Status().String())
+	assert.Equal(t, "Rejected", child.Status().String())
+	assert.ErrorIs(t, bc.checkState(child), ErrParentRejected)
+	assert.Empty(t, bc.pendingBlocks)
+}
+
+func blockIDs(blocks map[ids.ID]*Block) map[ids.ID]struct{} {
+	set := make(map[ids.ID]struct{}, len(blocks))
+	for id := range blocks {
+		set[id] = struct{}{}
+	}
+	return set
+}
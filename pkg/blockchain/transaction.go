@@ -29,19 +29,26 @@ type Transaction struct {
 	Recipient string              `json:"recipient"`
 	Amount    uint64              `json:"amount"`
 	Nonce     uint64              `json:"nonce"`
+	Fee       uint64              `json:"fee"`
 	Signature []byte              `json:"signature"`
 	status    choices.Status      `json:"status"`
 	deps      []snowstorm.Tx      `json:"dependencies"`
 	bytes     []byte              `json:"bytes"`
 }
 
-// NewTransaction creates a new transaction
+// NewTransaction creates a new transaction without a fee
 func NewTransaction(sender, recipient string, amount, nonce uint64) (*Transaction, error) {
+	return NewTransactionWithFee(sender, recipient, amount, nonce, 0)
+}
+
+// NewTransactionWithFee creates a new transaction paying the given fee
+func NewTransactionWithFee(sender, recipient string, amount, nonce, fee uint64) (*Transaction, error) {
 	tx := &Transaction{
 		Sender:    sender,
 		Recipient: recipient,
 		Amount:    amount,
 		Nonce:     nonce,
+		Fee:       fee,
 		status:    choices.Processing,
 	}
 
@@ -98,6 +105,10 @@ func (tx *Transaction) Bytes() []byte {
 
 // generateBytes creates the byte representation of the transaction
 func (tx *Transaction) generateBytes() ([]byte, error) {
+	// The fee is only encoded when set, so fee-less transactions keep their IDs
+	if tx.Fee > 0 {
+		return []byte(fmt.Sprintf("%s-%s-%d-%d-%d", tx.Sender, tx.Recipient, tx.Amount, tx.Nonce, tx.Fee)), nil
+	}
 	return []byte(fmt.Sprintf("%s-%s-%d-%d", tx.Sender, tx.Recipient, tx.Amount, tx.Nonce)), nil
 }
 
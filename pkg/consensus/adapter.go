@@ -7,13 +7,16 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/ava-labs/avalanchego/snow/choices"
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/snow/consensus/avalanche"
 	"github.com/ava-labs/avalanchego/snow/consensus/snowstorm"
-	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/hashing"
 	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"go.uber.org/zap"
 )
 
 // VertexAdapter adapts the base avalanche.Vertex to ParallelVertex
@@ -28,10 +31,10 @@ func NewVertexAdapter(vertex avalanche.Vertex, priority uint64) (*VertexAdapter,
 	if vertex == nil {
 		return nil, fmt.Errorf("cannot adapt nil vertex")
 	}
-	
+
 	// Create a vertex ID from the vertex bytes
-	id := ids.ID(ids.NewID(vertex.Bytes()))
-	
+	id := ids.ID(hashing.ComputeHash256Array(vertex.Bytes()))
+
 	return &VertexAdapter{
 		Vertex:   vertex,
 		id:       id,
@@ -49,6 +52,14 @@ func (va *VertexAdapter) GetProcessingPriority() uint64 {
 	return va.priority
 }
 
+// Verify verifies the wrapped vertex if it supports verification
+func (va *VertexAdapter) Verify(ctx context.Context) error {
+	if verifier, ok := va.Vertex.(interface{ Verify(context.Context) error }); ok {
+		return verifier.Verify(ctx)
+	}
+	return nil
+}
+
 // ParallelVertex is an extension of the avalanche.Vertex interface
 // that adds parallel processing capabilities
 type ParallelVertex interface {
@@ -56,6 +67,27 @@ type ParallelVertex interface {
 
 	// GetProcessingPriority returns the priority for processing this vertex
 	GetProcessingPriority() uint64
+
+	// Verify checks the vertex is valid before it is added to the engine
+	Verify(context.Context) error
+}
+
+// InputTx is a transaction that declares the inputs it consumes. Transactions
+// that consume the same input conflict; transactions that do not implement
+// InputTx never conflict.
+type InputTx interface {
+	snowstorm.Tx
+
+	// InputIDs returns the IDs of the inputs the transaction consumes
+	InputIDs() []ids.ID
+}
+
+// inputIDs returns the inputs consumed by tx, if it declares any
+func inputIDs(tx snowstorm.Tx) []ids.ID {
+	if inputTx, ok := tx.(InputTx); ok {
+		return inputTx.InputIDs()
+	}
+	return nil
 }
 
 // ParallelEngine implements the avalanche consensus engine with
@@ -65,11 +97,16 @@ type ParallelEngine struct {
 	logger      logging.Logger
 	running     bool
 	vertices    map[ids.ID]ParallelVertex
-	edgeMap     map[ids.ID][]ids.ID   // Map from vertex ID to parent IDs
-	conflicts   map[ids.ID]ids.Set    // Map of conflicting transaction IDs
-	maxWorkers  int                   // Maximum number of parallel workers
-	txsAccepted map[ids.ID]struct{}   // Set of accepted transaction IDs
-	txsRejected map[ids.ID]struct{}   // Set of rejected transaction IDs
+	edgeMap     map[ids.ID][]ids.ID        // Map from vertex ID to parent IDs
+	childMap    map[ids.ID][]ids.ID        // Map from vertex ID to child IDs
+	conflicts   map[ids.ID]set.Set[ids.ID] // Map of conflicting transaction IDs
+	maxWorkers  int                        // Maximum number of parallel workers
+	txsAccepted map[ids.ID]struct{}        // Set of accepted transaction IDs
+	txsRejected map[ids.ID]struct{}        // Set of rejected transaction IDs
+	scheduler   Scheduler                  // Batch scheduler used by ProcessVertices
+	workerStats []WorkerStats              // Cumulative statistics of the work-stealing workers
+
+	priorityInversions atomic.Uint64 // Ready vertices that outranked one being processed
 }
 
 // NewParallelEngine creates a new parallel consensus engine
@@ -83,21 +120,39 @@ func NewParallelEngine(logger logging.Logger, maxWorkers int) *ParallelEngine {
 		running:     false,
 		vertices:    make(map[ids.ID]ParallelVertex),
 		edgeMap:     make(map[ids.ID][]ids.ID),
-		conflicts:   make(map[ids.ID]ids.Set),
+		childMap:    make(map[ids.ID][]ids.ID),
+		conflicts:   make(map[ids.ID]set.Set[ids.ID]),
 		maxWorkers:  maxWorkers,
 		txsAccepted: make(map[ids.ID]struct{}),
 		txsRejected: make(map[ids.ID]struct{}),
+		scheduler:   WorkStealingScheduler,
+		workerStats: make([]WorkerStats, maxWorkers),
 	}
 }
 
-// ProcessVertex processes a single vertex through the consensus engine
+// ProcessVertex processes a single vertex through the consensus engine on the
+// calling goroutine. It is the unit of work of every scheduler: batches are
+// partitioned by height over the work-stealing deques by ProcessVertices,
+// whose workers call ProcessVertex for each vertex they take.
 func (e *ParallelEngine) ProcessVertex(ctx context.Context, vertex ParallelVertex) error {
-	e.lock.Lock()
-	defer e.lock.Unlock()
-
 	vertexID := vertex.ID()
 
 	// Check if already processed
+	e.lock.RLock()
+	_, exists := e.vertices[vertexID]
+	e.lock.RUnlock()
+	if exists {
+		return nil
+	}
+
+	// Verification does not touch engine state, so it runs without the lock
+	// and independent vertices can be verified concurrently
+	verifyErr := vertex.Verify(ctx)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	// Another caller may have processed the vertex in the meantime
 	if _, exists := e.vertices[vertexID]; exists {
 		return nil
 	}
@@ -113,16 +168,17 @@ func (e *ParallelEngine) ProcessVertex(ctx context.Context, vertex ParallelVerte
 	parentIDs := make([]ids.ID, 0, len(parents))
 	for _, parent := range parents {
 		parentIDs = append(parentIDs, parent.ID())
+		e.childMap[parent.ID()] = append(e.childMap[parent.ID()], vertexID)
 	}
 	e.edgeMap[vertexID] = parentIDs
 
-	// Verify the vertex
-	if err := vertex.Verify(ctx); err != nil {
+	// Check the verification result
+	if verifyErr != nil {
 		// If verification fails, reject the vertex
 		if err := vertex.Reject(ctx); err != nil {
 			return err
 		}
-		return nil
+		return fmt.Errorf("failed to verify vertex %s: %w", vertexID, verifyErr)
 	}
 
 	// Get transactions from vertex
@@ -143,16 +199,10 @@ func (e *ParallelEngine) ProcessVertex(ctx context.Context, vertex ParallelVerte
 			continue
 		}
 
-		// Check for conflicts with this transaction
-		inputs, err := tx.InputIDs()
-		if err != nil {
-			return err
-		}
-
-		// For each input, check for conflicts
-		for _, inputID := range inputs {
+		// Record the transaction against each input it consumes
+		for _, inputID := range inputIDs(tx) {
 			if _, exists := e.conflicts[inputID]; !exists {
-				e.conflicts[inputID] = ids.NewSet(0)
+				e.conflicts[inputID] = set.Empty[ids.ID]()
 			}
 			e.conflicts[inputID].Add(txID)
 		}
@@ -169,7 +219,7 @@ func (e *ParallelEngine) BatchProcessVertices(ctx context.Context, vertices []av
 		if pv, ok := vertex.(ParallelVertex); ok {
 			parallelVertices = append(parallelVertices, pv)
 		} else {
-			e.logger.Warn("Vertex does not implement ParallelVertex interface: %s", vertex.ID())
+			e.logger.Warn("Vertex does not implement ParallelVertex interface", zap.Stringer("vertexID", vertex.ID()))
 		}
 	}
 
@@ -245,15 +295,12 @@ func (e *ParallelEngine) DecideTxs(ctx context.Context) error {
 
 				// Check if all conflicts are rejected, if so we can accept this tx
 				canAccept := true
-				inputs, err := tx.InputIDs()
-				if err != nil {
-					return err
-				}
+				inputs := inputIDs(tx)
 
 				for _, inputID := range inputs {
 					if conflicts, exists := e.conflicts[inputID]; exists {
-						for conflictTxID := range conflicts {
-							if conflictTxID.Equals(txID) {
+						for _, conflictTxID := range conflicts.List() {
+							if conflictTxID == txID {
 								continue
 							}
 							if _, rejected := e.txsRejected[conflictTxID]; !rejected {
@@ -278,15 +325,15 @@ func (e *ParallelEngine) DecideTxs(ctx context.Context) error {
 					// Reject all conflicting transactions
 					for _, inputID := range inputs {
 						if conflicts, exists := e.conflicts[inputID]; exists {
-							for conflictTxID := range conflicts {
-								if conflictTxID.Equals(txID) {
+							for _, conflictTxID := range conflicts.List() {
+								if conflictTxID == txID {
 									continue
 								}
 								// Get the conflicting transaction and reject it
 								for _, v := range e.vertices {
 									vtxTxs, _ := v.Txs(ctx)
 									for _, vtxTx := range vtxTxs {
-										if vtxTx.ID().Equals(conflictTxID) {
+										if vtxTx.ID() == conflictTxID {
 											if err := vtxTx.Reject(ctx); err != nil {
 												return err
 											}
@@ -384,8 +431,8 @@ func (e *ParallelEngine) RunConsensus(ctx context.Context, interval time.Duratio
 			return
 		case <-ticker.C:
 			if err := e.DecideTxs(ctx); err != nil {
-				e.logger.Error("Error deciding transactions: %s", err)
+				e.logger.Error("Error deciding transactions", zap.Error(err))
 			}
 		}
 	}
-} 
+}
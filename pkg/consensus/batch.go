@@ -0,0 +1,237 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package consensus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/consensus/avalanche"
+)
+
+var (
+	// ErrParentFailed is recorded for vertices whose parent failed in the same batch
+	ErrParentFailed = errors.New("parent vertex failed")
+
+	// ErrInvalidDependency is recorded for vertices in a dependency cycle
+	ErrInvalidDependency = errors.New("invalid dependency")
+)
+
+// vertexResult is the outcome of processing one vertex of a batch
+type vertexResult struct {
+	index int
+	err   error
+}
+
+// batchSchedule tracks the parent-before-child ordering of a vertex batch.
+// Vertices are referred to by their index in batch order. Finishing a vertex
+// is safe to call from several goroutines at once.
+type batchSchedule struct {
+	vertices []ParallelVertex
+	children [][]int        // In-batch children of each vertex
+	waiting  []atomic.Int32 // Unfinished in-batch parents of each vertex
+	failedBy []atomic.Int32 // 1 + index of the first failed parent, 0 if none
+	finished []atomic.Bool
+	errs     []error          // Written once, by the goroutine that finishes the vertex
+	ready    []int            // Vertices with no unfinished in-batch parents
+	rejected map[ids.ID]error // Vertices that are not ParallelVertex
+}
+
+// ProcessVertices processes a batch of vertices in parent-before-child order.
+//
+// The batch is ordered by its parent relationships. Every vertex whose
+// parents have finished, or are not part of the batch, is handed to the
+// worker pool at once, and children are released as their parents complete,
+// so independent vertices are processed concurrently. Among ready vertices,
+// those with the most descendants, as estimated by ComputePriority and the
+// batch itself, are dispatched first. How ready vertices are distributed over
+// the workers is chosen with SetScheduler.
+//
+// The returned map holds the error of every vertex that failed and is empty
+// when the whole batch succeeded. Children of a failed vertex are not
+// processed and fail with ErrParentFailed. Vertices in a dependency cycle fail
+// with ErrInvalidDependency. Once ctx is cancelled no further vertices are
+// dispatched; those left over fail with the context error.
+func (e *ParallelEngine) ProcessVertices(ctx context.Context, vertices []avalanche.Vertex) map[ids.ID]error {
+	schedule := newBatchSchedule(vertices)
+
+	e.lock.RLock()
+	scheduler := e.scheduler
+	e.lock.RUnlock()
+
+	switch scheduler {
+	case CentralScheduler:
+		e.processCentral(ctx, schedule)
+	default:
+		e.processWorkStealing(ctx, schedule)
+	}
+	return schedule.results(ctx)
+}
+
+// processCentral dispatches ready vertices from a single coordinating
+// goroutine, starting one goroutine per vertex up to the worker limit. Ready
+// vertices are taken from a priority heap, so those with the most
+// descendants are processed first.
+func (e *ParallelEngine) processCentral(ctx context.Context, schedule *batchSchedule) {
+	priorities := e.batchPriorities(schedule)
+	ready := &priorityQueue{}
+	enqueue := func(i int) {
+		ready.push(newPriorityItem(schedule, priorities, i))
+	}
+	for _, i := range schedule.ready {
+		enqueue(i)
+	}
+
+	results := make(chan vertexResult, e.maxWorkers)
+	inFlight := make(map[int]float64, e.maxWorkers) // Priority of each vertex being processed
+	for ready.len() > 0 || len(inFlight) > 0 {
+		// Dispatch every ready vertex the worker pool has room for
+		for ready.len() > 0 && len(inFlight) < e.maxWorkers && ctx.Err() == nil {
+			item, _ := ready.pop()
+			inFlight[item.index] = item.priority
+
+			go func(i int) {
+				results <- vertexResult{index: i, err: e.ProcessVertex(ctx, schedule.vertices[i])}
+			}(item.index)
+		}
+		if len(inFlight) == 0 {
+			break // Cancelled with nothing left in flight
+		}
+
+		// Wait for a vertex to finish, then release its children
+		result := <-results
+		delete(inFlight, result.index)
+		for _, child := range schedule.finish(result.index, result.err) {
+			if outranksAny(priorities[child], inFlight) {
+				e.priorityInversions.Add(1)
+			}
+			enqueue(child)
+		}
+	}
+}
+
+// outranksAny reports whether priority is higher than that of any vertex in flight
+func outranksAny(priority float64, inFlight map[int]float64) bool {
+	for _, other := range inFlight {
+		if priority > other {
+			return true
+		}
+	}
+	return false
+}
+
+// newBatchSchedule indexes a batch and computes the initial ready set
+func newBatchSchedule(vertices []avalanche.Vertex) *batchSchedule {
+	s := &batchSchedule{
+		vertices: make([]ParallelVertex, 0, len(vertices)),
+		rejected: make(map[ids.ID]error),
+	}
+
+	index := make(map[ids.ID]int, len(vertices))
+	for _, vertex := range vertices {
+		pv, ok := vertex.(ParallelVertex)
+		if !ok {
+			s.rejected[vertex.ID()] = fmt.Errorf("vertex %s does not implement ParallelVertex", vertex.ID())
+			continue
+		}
+		if _, duplicate := index[pv.ID()]; duplicate {
+			continue
+		}
+		index[pv.ID()] = len(s.vertices)
+		s.vertices = append(s.vertices, pv)
+	}
+
+	n := len(s.vertices)
+	s.children = make([][]int, n)
+	s.waiting = make([]atomic.Int32, n)
+	s.failedBy = make([]atomic.Int32, n)
+	s.finished = make([]atomic.Bool, n)
+	s.errs = make([]error, n)
+
+	// Link each vertex to the parents it has in the batch
+	invalid := make(map[int]error)
+	for i, vertex := range s.vertices {
+		parents, err := vertex.Parents()
+		if err != nil {
+			invalid[i] = err
+			continue
+		}
+		for _, parent := range parents {
+			p, inBatch := index[parent.ID()]
+			if !inBatch {
+				continue // Parents outside the batch are already known to the engine
+			}
+			s.children[p] = append(s.children[p], i)
+			s.waiting[i].Add(1)
+		}
+	}
+
+	for i := range s.vertices {
+		if _, isInvalid := invalid[i]; !isInvalid && s.waiting[i].Load() == 0 {
+			s.ready = append(s.ready, i)
+		}
+	}
+	for i := range s.vertices {
+		if err, isInvalid := invalid[i]; isInvalid {
+			s.ready = append(s.ready, s.finish(i, err)...)
+		}
+	}
+	return s
+}
+
+// finish records the outcome of a vertex and returns the children it made
+// ready. Children of a failed vertex are finished immediately with
+// ErrParentFailed instead of being returned.
+func (s *batchSchedule) finish(i int, err error) []int {
+	if !s.finished[i].CompareAndSwap(false, true) {
+		return nil
+	}
+	s.errs[i] = err
+
+	var released []int
+	for _, child := range s.children[i] {
+		// The failure is recorded before the count drops, so whichever
+		// parent finishes last sees it
+		if err != nil {
+			s.failedBy[child].CompareAndSwap(0, int32(i)+1)
+		}
+		if s.waiting[child].Add(-1) > 0 {
+			continue
+		}
+		if parent := s.failedBy[child].Load(); parent > 0 {
+			parentErr := fmt.Errorf("%w: %s", ErrParentFailed, s.vertices[parent-1].ID())
+			released = append(released, s.finish(child, parentErr)...)
+			continue
+		}
+		released = append(released, child)
+	}
+	return released
+}
+
+// results collects the errors of the batch once processing has stopped.
+// Anything not finished was either cut off by cancellation or is part of a
+// cycle.
+func (s *batchSchedule) results(ctx context.Context) map[ids.ID]error {
+	errs := make(map[ids.ID]error, len(s.rejected))
+	for id, err := range s.rejected {
+		errs[id] = err
+	}
+
+	for i, vertex := range s.vertices {
+		switch {
+		case s.finished[i].Load():
+			if s.errs[i] != nil {
+				errs[vertex.ID()] = s.errs[i]
+			}
+		case ctx.Err() != nil:
+			errs[vertex.ID()] = ctx.Err()
+		default:
+			errs[vertex.ID()] = ErrInvalidDependency
+		}
+	}
+	return errs
+}
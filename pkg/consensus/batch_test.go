@@ -0,0 +1,242 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package consensus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
+	"github.com/ava-labs/avalanchego/snow/consensus/avalanche"
+	"github.com/ava-labs/avalanchego/snow/consensus/snowstorm"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timeline records when each vertex was verified
+type timeline struct {
+	lock   sync.Mutex
+	starts map[ids.ID]time.Time
+	ends   map[ids.ID]time.Time
+}
+
+func newTimeline() *timeline {
+	return &timeline{
+		starts: make(map[ids.ID]time.Time),
+		ends:   make(map[ids.ID]time.Time),
+	}
+}
+
+func (tl *timeline) record(times map[ids.ID]time.Time, id ids.ID) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	times[id] = time.Now()
+}
+
+// schedulers lists the batch schedulers every batch test runs against
+var schedulers = []struct {
+	name      string
+	scheduler Scheduler
+}{
+	{"WorkStealing", WorkStealingScheduler},
+	{"Central", CentralScheduler},
+}
+
+// runWithSchedulers runs a test once per batch scheduler
+func runWithSchedulers(t *testing.T, test func(t *testing.T, scheduler Scheduler)) {
+	for _, s := range schedulers {
+		s := s
+		t.Run(s.name, func(t *testing.T) {
+			test(t, s.scheduler)
+		})
+	}
+}
+
+// newTestEngine creates an engine using the given batch scheduler
+func newTestEngine(maxWorkers int, scheduler Scheduler) *ParallelEngine {
+	engine := NewParallelEngine(logging.NoLog{}, maxWorkers)
+	engine.SetScheduler(scheduler)
+	return engine
+}
+
+// testVertex is a ParallelVertex whose verification can be observed
+type testVertex struct {
+	id        ids.ID
+	height    uint64
+	status    choices.Status
+	parents   []avalanche.Vertex
+	txsErr    error
+	verifyErr error
+	timeline  *timeline // Optional
+	onVerify  func()
+	cost      time.Duration // How long verification takes
+}
+
+func newTestVertex(tl *timeline, height uint64, parents ...avalanche.Vertex) *testVertex {
+	return &testVertex{
+		id:       ids.GenerateTestID(),
+		height:   height,
+		status:   choices.Processing,
+		parents:  parents,
+		timeline: tl,
+		cost:     5 * time.Millisecond,
+	}
+}
+
+func (v *testVertex) ID() ids.ID                                  { return v.id }
+func (v *testVertex) Accept(context.Context) error                { v.status = choices.Accepted; return nil }
+func (v *testVertex) Reject(context.Context) error                { v.status = choices.Rejected; return nil }
+func (v *testVertex) Status() choices.Status                      { return v.status }
+func (v *testVertex) Parents() ([]avalanche.Vertex, error)        { return v.parents, nil }
+func (v *testVertex) Height() (uint64, error)                     { return v.height, nil }
+func (v *testVertex) Txs(context.Context) ([]snowstorm.Tx, error) { return nil, v.txsErr }
+func (v *testVertex) Bytes() []byte                               { return v.id[:] }
+func (v *testVertex) GetProcessingPriority() uint64               { return v.height }
+
+func (v *testVertex) Verify(context.Context) error {
+	if v.timeline != nil {
+		v.timeline.record(v.timeline.starts, v.id)
+	}
+	if v.onVerify != nil {
+		v.onVerify()
+	}
+	time.Sleep(v.cost)
+	if v.timeline != nil {
+		v.timeline.record(v.timeline.ends, v.id)
+	}
+	return v.verifyErr
+}
+
+// diamond builds a <- {b, c} <- d
+func diamond(tl *timeline) (a, b, c, d *testVertex) {
+	a = newTestVertex(tl, 1)
+	b = newTestVertex(tl, 2, a)
+	c = newTestVertex(tl, 2, a)
+	d = newTestVertex(tl, 3, b, c)
+	return a, b, c, d
+}
+
+func TestProcessVerticesDiamond(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		tl := newTimeline()
+		a, b, c, d := diamond(tl)
+
+		// b and c only return once both have started, which proves they overlap
+		var arrived sync.WaitGroup
+		arrived.Add(2)
+		overlapped := make(chan struct{})
+		go func() {
+			arrived.Wait()
+			close(overlapped)
+		}()
+		waitForSibling := func() {
+			arrived.Done()
+			select {
+			case <-overlapped:
+			case <-time.After(time.Second):
+			}
+		}
+		b.onVerify = waitForSibling
+		c.onVerify = waitForSibling
+
+		engine := newTestEngine(4, scheduler)
+
+		// Children are listed first to show the batch order does not matter
+		errs := engine.ProcessVertices(context.Background(), []avalanche.Vertex{d, c, b, a})
+		require.Empty(t, errs)
+
+		select {
+		case <-overlapped:
+		default:
+			t.Fatal("siblings were not processed concurrently")
+		}
+
+		edges := [][2]*testVertex{{a, b}, {a, c}, {b, d}, {c, d}}
+		for _, edge := range edges {
+			parent, child := edge[0], edge[1]
+			assert.False(t, tl.starts[child.id].Before(tl.ends[parent.id]),
+				"vertex at height %d started before its parent finished", child.height)
+		}
+		assert.Len(t, engine.vertices, 4)
+	})
+}
+
+func TestProcessVerticesFailedParent(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		tl := newTimeline()
+		a, b, c, d := diamond(tl)
+		b.txsErr = errors.New("corrupt vertex")
+
+		engine := newTestEngine(4, scheduler)
+		errs := engine.ProcessVertices(context.Background(), []avalanche.Vertex{a, b, c, d})
+
+		require.Len(t, errs, 2)
+		assert.ErrorIs(t, errs[b.id], b.txsErr)
+		assert.ErrorIs(t, errs[d.id], ErrParentFailed)
+		assert.NotContains(t, errs, c.id)
+
+		// The child of the failed vertex is never processed
+		_, verified := tl.starts[d.id]
+		assert.False(t, verified)
+	})
+}
+
+func TestProcessVerticesFailedVerification(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		tl := newTimeline()
+		a, b, c, d := diamond(tl)
+		c.verifyErr = errors.New("bad signature")
+
+		engine := newTestEngine(4, scheduler)
+		errs := engine.ProcessVertices(context.Background(), []avalanche.Vertex{a, b, c, d})
+
+		require.Len(t, errs, 2)
+		assert.ErrorIs(t, errs[c.id], c.verifyErr)
+		assert.ErrorIs(t, errs[d.id], ErrParentFailed)
+		assert.Equal(t, choices.Rejected, c.status)
+
+		_, verified := tl.starts[d.id]
+		assert.False(t, verified)
+	})
+}
+
+func TestProcessVerticesCancellation(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		tl := newTimeline()
+		a, b, c, d := diamond(tl)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		a.onVerify = cancel
+
+		engine := newTestEngine(4, scheduler)
+		errs := engine.ProcessVertices(ctx, []avalanche.Vertex{a, b, c, d})
+
+		assert.NotContains(t, errs, a.id)
+		for _, vertex := range []*testVertex{b, c, d} {
+			assert.ErrorIs(t, errs[vertex.id], context.Canceled)
+		}
+	})
+}
+
+func TestProcessVerticesCycle(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		tl := newTimeline()
+		x := newTestVertex(tl, 1)
+		y := newTestVertex(tl, 2, x)
+		x.parents = []avalanche.Vertex{y}
+
+		engine := newTestEngine(2, scheduler)
+		errs := engine.ProcessVertices(context.Background(), []avalanche.Vertex{x, y})
+
+		assert.ErrorIs(t, errs[x.id], ErrInvalidDependency)
+		assert.ErrorIs(t, errs[y.id], ErrInvalidDependency)
+	})
+}
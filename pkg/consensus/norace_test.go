@@ -0,0 +1,9 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+//go:build !race
+
+package consensus
+
+// raceEnabled reports whether the race detector, which distorts timings, is on
+const raceEnabled = false
@@ -0,0 +1,218 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package consensus
+
+import (
+	"container/heap"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/metrics"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/consensus/avalanche"
+)
+
+// ComputePriority estimates how many vertices known to the engine descend
+// from v. Vertices that unblock the most downstream work rank highest.
+func (e *ParallelEngine) ComputePriority(v avalanche.Vertex) float64 {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return newDescendantCounter(e.childMap).count(v.ID())
+}
+
+// PriorityInversions returns how often a vertex became ready while a vertex
+// of lower priority was being processed
+func (e *ParallelEngine) PriorityInversions() uint64 {
+	return e.priorityInversions.Load()
+}
+
+// WriteMetrics writes the engine's metrics in the Prometheus text format
+func (e *ParallelEngine) WriteMetrics(w io.Writer) error {
+	return metrics.Write(w, metrics.NewCounter(
+		"priority_inversions_total",
+		"Ready vertices that outranked a vertex already being processed",
+		float64(e.PriorityInversions()),
+	))
+}
+
+// batchPriorities returns the priority of every vertex of a batch: its
+// ComputePriority estimate plus the descendants it has within the batch
+func (e *ParallelEngine) batchPriorities(s *batchSchedule) []float64 {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	known := newDescendantCounter(e.childMap)
+	priorities := make([]float64, len(s.vertices))
+	state := make([]uint8, len(s.vertices))
+
+	var visit func(i int) float64
+	visit = func(i int) float64 {
+		switch state[i] {
+		case visiting:
+			return 0 // Dependency cycle
+		case visited:
+			return priorities[i]
+		}
+		state[i] = visiting
+
+		total := 0.0
+		if id := s.vertices[i].ID(); len(e.childMap[id]) > 0 {
+			total = known.count(id)
+		}
+		for _, child := range s.children[i] {
+			if _, isKnown := e.vertices[s.vertices[child].ID()]; isKnown {
+				continue // Already counted through childMap
+			}
+			total += 1 + visit(child)
+		}
+
+		state[i] = visited
+		priorities[i] = total
+		return total
+	}
+	for i := range s.vertices {
+		visit(i)
+	}
+	return priorities
+}
+
+// descendantCounter estimates descendant counts with a depth-first pass over
+// child edges. A vertex reachable along several paths is counted once per
+// path, which overestimates diamonds but keeps the pass linear.
+type descendantCounter struct {
+	children map[ids.ID][]ids.ID
+	memo     map[ids.ID]float64
+	visiting map[ids.ID]bool
+}
+
+func newDescendantCounter(children map[ids.ID][]ids.ID) *descendantCounter {
+	return &descendantCounter{
+		children: children,
+		memo:     make(map[ids.ID]float64),
+		visiting: make(map[ids.ID]bool),
+	}
+}
+
+// count returns the descendant estimate of a vertex
+func (c *descendantCounter) count(id ids.ID) float64 {
+	if total, done := c.memo[id]; done {
+		return total
+	}
+	if c.visiting[id] {
+		return 0 // Dependency cycle
+	}
+
+	c.visiting[id] = true
+	total := 0.0
+	for _, child := range c.children[id] {
+		total += 1 + c.count(child)
+	}
+	delete(c.visiting, id)
+
+	c.memo[id] = total
+	return total
+}
+
+// priorityItem is a ready vertex of a batch
+type priorityItem struct {
+	index    int
+	priority float64 // Descendant estimate
+	tiebreak uint64  // GetProcessingPriority of the vertex
+}
+
+// newPriorityItem returns the ready entry for vertex i of a batch
+func newPriorityItem(s *batchSchedule, priorities []float64, i int) priorityItem {
+	return priorityItem{
+		index:    i,
+		priority: priorities[i],
+		tiebreak: s.vertices[i].GetProcessingPriority(),
+	}
+}
+
+// outranks reports whether the item should be processed before other: the
+// one with the most descendants goes first, then the one with the highest
+// processing priority, then the earliest in batch order.
+//
+// GetProcessingPriority is only a tiebreak. It is assigned by whoever built
+// the vertex and says nothing about the work a vertex unblocks, so keying the
+// heap on it would leave bottleneck vertices behind ones nothing waits for.
+func (item priorityItem) outranks(other priorityItem) bool {
+	if item.priority != other.priority {
+		return item.priority > other.priority
+	}
+	if item.tiebreak != other.tiebreak {
+		return item.tiebreak > other.tiebreak
+	}
+	return item.index < other.index
+}
+
+// sortLowestFirst orders batch indices so the vertex to process next is last,
+// which is the end a work-stealing deque pops from
+func sortLowestFirst(s *batchSchedule, priorities []float64, indices []int) {
+	if len(indices) < 2 {
+		return
+	}
+	sort.Slice(indices, func(a, b int) bool {
+		return newPriorityItem(s, priorities, indices[b]).outranks(newPriorityItem(s, priorities, indices[a]))
+	})
+}
+
+// priorityItems is a heap whose root is the item that outranks all others
+type priorityItems []priorityItem
+
+func (h priorityItems) Len() int { return len(h) }
+
+func (h priorityItems) Less(i, j int) bool { return h[i].outranks(h[j]) }
+
+func (h priorityItems) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *priorityItems) Push(x interface{}) { *h = append(*h, x.(priorityItem)) }
+
+func (h *priorityItems) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// priorityQueue is a thread-safe heap of ready vertices
+type priorityQueue struct {
+	lock  sync.Mutex
+	items priorityItems
+}
+
+// push adds a ready vertex
+func (q *priorityQueue) push(item priorityItem) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	heap.Push(&q.items, item)
+}
+
+// pop removes the vertex to process next
+func (q *priorityQueue) pop() (priorityItem, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.items) == 0 {
+		return priorityItem{}, false
+	}
+	return heap.Pop(&q.items).(priorityItem), true
+}
+
+// len returns the number of ready vertices
+func (q *priorityQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return len(q.items)
+}
@@ -0,0 +1,192 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package consensus
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/consensus/avalanche"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processingOrder records the order in which vertices start verification
+type processingOrder struct {
+	lock  sync.Mutex
+	order []ids.ID
+}
+
+func (o *processingOrder) observe(v *testVertex) {
+	v.onVerify = func() {
+		o.lock.Lock()
+		defer o.lock.Unlock()
+
+		o.order = append(o.order, v.id)
+	}
+}
+
+func TestProcessVerticesStarRootFirst(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		order := &processingOrder{}
+
+		root := newTestVertex(nil, 1)
+		vertices := make([]avalanche.Vertex, 0, 111)
+
+		// Unrelated vertices are listed ahead of the root
+		for i := 0; i < 10; i++ {
+			vertex := newTestVertex(nil, 1)
+			vertex.cost = 0
+			vertices = append(vertices, vertex)
+		}
+		leaves := make([]*testVertex, 0, 100)
+		for i := 0; i < 100; i++ {
+			leaf := newTestVertex(nil, 2, root)
+			leaf.cost = 0
+			leaves = append(leaves, leaf)
+			vertices = append(vertices, leaf)
+		}
+		vertices = append(vertices, root)
+		for _, vertex := range vertices {
+			order.observe(vertex.(*testVertex))
+		}
+
+		engine := newTestEngine(1, scheduler)
+		require.Empty(t, engine.ProcessVertices(context.Background(), vertices))
+
+		require.Len(t, order.order, len(vertices))
+		assert.Equal(t, root.id, order.order[0])
+
+		assert.Equal(t, float64(100), engine.ComputePriority(root))
+		assert.Zero(t, engine.ComputePriority(leaves[0]))
+	})
+}
+
+// twoRoots builds two independent roots, one with a single child and one
+// with five, listed in the given order
+func twoRoots(order *processingOrder, bigFirst bool) (small, big *testVertex, vertices []avalanche.Vertex) {
+	small = newTestVertex(nil, 1)
+	big = newTestVertex(nil, 1)
+	smallTree := []avalanche.Vertex{small, newTestVertex(nil, 2, small)}
+	bigTree := []avalanche.Vertex{big}
+	for i := 0; i < 5; i++ {
+		bigTree = append(bigTree, newTestVertex(nil, 2, big))
+	}
+
+	if bigFirst {
+		vertices = append(bigTree, smallTree...)
+	} else {
+		vertices = append(smallTree, bigTree...)
+	}
+	for _, vertex := range vertices {
+		vertex.(*testVertex).cost = 0
+		order.observe(vertex.(*testVertex))
+	}
+	return small, big, vertices
+}
+
+func TestProcessVerticesDispatchesByDescendants(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		for _, bigFirst := range []bool{false, true} {
+			order := &processingOrder{}
+			small, big, vertices := twoRoots(order, bigFirst)
+
+			engine := newTestEngine(1, scheduler)
+			require.Empty(t, engine.ProcessVertices(context.Background(), vertices))
+
+			require.Len(t, order.order, len(vertices))
+			assert.Equal(t, big.id, order.order[0], "big root listed first: %v", bigFirst)
+
+			// The central heap also ranks the small root above the leaves of
+			// the big one, which a worker pushes onto its deque after it
+			if scheduler == CentralScheduler {
+				assert.Equal(t, small.id, order.order[1], "big root listed first: %v", bigFirst)
+			}
+		}
+	})
+}
+
+func TestProcessVerticesCountsKnownDescendants(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		engine := newTestEngine(1, scheduler)
+
+		// The children of late arrive before it, in an earlier batch
+		late := newTestVertex(nil, 1)
+		early := make([]avalanche.Vertex, 0, 3)
+		for i := 0; i < 3; i++ {
+			early = append(early, newTestVertex(nil, 2, late))
+		}
+		require.Empty(t, engine.ProcessVertices(context.Background(), early))
+		assert.Equal(t, float64(3), engine.ComputePriority(late))
+
+		order := &processingOrder{}
+		other := newTestVertex(nil, 1)
+		vertices := []avalanche.Vertex{other, newTestVertex(nil, 2, other), late}
+		for _, vertex := range vertices {
+			order.observe(vertex.(*testVertex))
+		}
+		require.Empty(t, engine.ProcessVertices(context.Background(), vertices))
+
+		require.Len(t, order.order, len(vertices))
+		assert.Equal(t, late.id, order.order[0])
+	})
+}
+
+func TestProcessVerticesCountsPriorityInversions(t *testing.T) {
+	runWithSchedulers(t, func(t *testing.T, scheduler Scheduler) {
+		// b <- c <- {d0..d4}, alongside an unrelated slow vertex a
+		a := newTestVertex(nil, 1)
+		a.cost = 200 * time.Millisecond
+		b := newTestVertex(nil, 1)
+		b.cost = 50 * time.Millisecond // Long enough for the other worker to pick up a
+		c := newTestVertex(nil, 2, b)
+		vertices := []avalanche.Vertex{a, b, c}
+		for i := 0; i < 5; i++ {
+			vertices = append(vertices, newTestVertex(nil, 3, c))
+		}
+
+		engine := newTestEngine(2, scheduler)
+		require.Empty(t, engine.ProcessVertices(context.Background(), vertices))
+
+		// c becomes ready while a, which has no descendants, is still running;
+		// the leaves released after c do not outrank a
+		assert.Equal(t, uint64(1), engine.PriorityInversions())
+
+		var out strings.Builder
+		require.NoError(t, engine.WriteMetrics(&out))
+		assert.Contains(t, out.String(), "# TYPE priority_inversions_total counter\npriority_inversions_total 1\n")
+	})
+}
+
+func TestDescendantCounterHandlesCycles(t *testing.T) {
+	x, y, z := ids.GenerateTestID(), ids.GenerateTestID(), ids.GenerateTestID()
+	counter := newDescendantCounter(map[ids.ID][]ids.ID{
+		x: {y},
+		y: {x, z},
+	})
+
+	assert.Equal(t, float64(3), counter.count(x))
+	assert.Zero(t, counter.count(z))
+}
+
+func TestPriorityQueueOrder(t *testing.T) {
+	queue := &priorityQueue{}
+	queue.push(priorityItem{index: 0, priority: 1, tiebreak: 5})
+	queue.push(priorityItem{index: 1, priority: 3, tiebreak: 0})
+	queue.push(priorityItem{index: 2, priority: 1, tiebreak: 9})
+	queue.push(priorityItem{index: 3, priority: 1, tiebreak: 9})
+
+	want := []int{1, 2, 3, 0}
+	for _, index := range want {
+		item, ok := queue.pop()
+		require.True(t, ok)
+		assert.Equal(t, index, item.index)
+	}
+	_, ok := queue.pop()
+	assert.False(t, ok)
+}
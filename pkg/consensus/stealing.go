@@ -0,0 +1,331 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package consensus
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// notRunning marks a work-stealing worker that is not processing a vertex.
+// Priorities are never negative, so it cannot collide with one.
+var notRunning = math.Float64bits(-1)
+
+// Scheduler selects how ProcessVertices distributes a batch over the workers
+type Scheduler int
+
+const (
+	// WorkStealingScheduler gives each worker a local deque of ready vertices
+	// and lets idle workers steal from the others. It is the default.
+	WorkStealingScheduler Scheduler = iota
+
+	// CentralScheduler dispatches ready vertices from a single coordinating
+	// goroutine, those with the most descendants first
+	CentralScheduler
+)
+
+// WorkerStats reports the work done by one work-stealing worker
+type WorkerStats struct {
+	Processed uint64        `json:"processed"` // Vertices processed by the worker
+	Stolen    uint64        `json:"stolen"`    // Vertices taken from another worker's deque
+	Idle      time.Duration `json:"idle"`      // Time spent without work while the batch was running
+}
+
+// SetScheduler selects the batch scheduler used by ProcessVertices
+func (e *ParallelEngine) SetScheduler(scheduler Scheduler) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.scheduler = scheduler
+}
+
+// WorkerStats returns the cumulative statistics of each work-stealing worker
+func (e *ParallelEngine) WorkerStats() []WorkerStats {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return append([]WorkerStats(nil), e.workerStats...)
+}
+
+// processWorkStealing runs a batch on maxWorkers workers with local deques.
+//
+// Initially ready vertices are partitioned by height over the workers'
+// deques. A worker pushes the children it releases onto its own deque, so a
+// dependency chain stays on one worker, and a worker whose deque is empty
+// steals the oldest vertex from a randomly chosen other worker. Workers that
+// find nothing to steal park until more vertices are queued.
+//
+// The initially ready vertices, and the children released by each vertex,
+// are pushed lowest priority first, so of the vertices queued together a
+// worker pops the one with the most descendants first while thieves take the
+// least urgent ones. Unlike the central heap this ordering is not global:
+// children a worker releases run before older vertices in its deque.
+func (e *ParallelEngine) processWorkStealing(ctx context.Context, schedule *batchSchedule) {
+	workers := e.maxWorkers
+	run := newStealingRun(schedule, e.batchPriorities(schedule), workers)
+
+	ready := append([]int(nil), schedule.ready...)
+	sortLowestFirst(schedule, run.priorities, ready)
+	for _, i := range ready {
+		owner := 0
+		if height, err := schedule.vertices[i].Height(); err == nil {
+			owner = int(height % uint64(workers))
+		}
+		run.pending.Add(1)
+		run.push(owner, i)
+	}
+
+	// Parked workers must notice cancellation
+	stop := context.AfterFunc(ctx, run.wakeAll)
+	defer stop()
+
+	stats := make([]WorkerStats, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			e.runStealingWorker(ctx, run, w, &stats[w])
+		}(w)
+	}
+	wg.Wait()
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for w := range stats {
+		e.workerStats[w].Processed += stats[w].Processed
+		e.workerStats[w].Stolen += stats[w].Stolen
+		e.workerStats[w].Idle += stats[w].Idle
+	}
+}
+
+// runStealingWorker processes vertices from its own deque, stealing when it
+// runs dry, until the batch is done or ctx is cancelled
+func (e *ParallelEngine) runStealingWorker(ctx context.Context, run *stealingRun, w int, stats *WorkerStats) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(w)))
+
+	for ctx.Err() == nil {
+		i, stolen, ok := run.take(w, rng)
+		if !ok {
+			idleSince := time.Now()
+			more := run.park(ctx)
+			stats.Idle += time.Since(idleSince)
+			if !more {
+				return
+			}
+			continue
+		}
+
+		run.running[w].Store(math.Float64bits(run.priorities[i]))
+		err := e.ProcessVertex(ctx, run.schedule.vertices[i])
+		run.running[w].Store(notRunning)
+		stats.Processed++
+		if stolen {
+			stats.Stolen++
+		}
+
+		// Children are queued before this vertex stops counting as pending
+		released := run.schedule.finish(i, err)
+		sortLowestFirst(run.schedule, run.priorities, released)
+		for _, child := range released {
+			if run.outranksRunning(run.priorities[child]) {
+				e.priorityInversions.Add(1)
+			}
+			run.pending.Add(1)
+			run.push(w, child)
+		}
+		if run.pending.Add(-1) == 0 {
+			run.wakeAll()
+		}
+	}
+}
+
+// stealingRun is the state the workers of one work-stealing batch share
+type stealingRun struct {
+	schedule   *batchSchedule
+	priorities []float64
+	deques     []*workDeque
+	running    []atomic.Uint64 // Float64bits of the priority each worker is processing, or notRunning
+
+	pending atomic.Int64 // Vertices queued or being processed; once zero no more work can appear
+	queued  atomic.Int64 // Vertices sitting in a deque
+	parked  atomic.Int32 // Workers waiting for work
+
+	lock sync.Mutex
+	wake *sync.Cond
+}
+
+func newStealingRun(schedule *batchSchedule, priorities []float64, workers int) *stealingRun {
+	run := &stealingRun{
+		schedule:   schedule,
+		priorities: priorities,
+		deques:     make([]*workDeque, workers),
+		running:    make([]atomic.Uint64, workers),
+	}
+	for w := range run.deques {
+		run.deques[w] = newWorkDeque(len(schedule.vertices))
+		run.running[w].Store(notRunning)
+	}
+	run.wake = sync.NewCond(&run.lock)
+	return run
+}
+
+// push queues a vertex on worker w's deque. Only worker w, or the
+// coordinator before the workers start, may call it.
+func (r *stealingRun) push(w int, i int) {
+	queued := r.queued.Add(1)
+	r.deques[w].push(i)
+
+	// The pushing worker takes one vertex itself, so a parked worker is only
+	// woken once there is a spare one
+	if queued > 1 && r.parked.Load() > 0 {
+		r.lock.Lock()
+		r.wake.Signal()
+		r.lock.Unlock()
+	}
+}
+
+// take pops a vertex from worker w's deque, or steals one from another worker
+func (r *stealingRun) take(w int, rng *rand.Rand) (i int, stolen bool, ok bool) {
+	if i, ok = r.deques[w].pop(); !ok {
+		i, ok = steal(r.deques, w, rng)
+		stolen = ok
+	}
+	if ok {
+		r.queued.Add(-1)
+	}
+	return i, stolen, ok
+}
+
+// park blocks until a vertex may be available to steal. It returns false once
+// the batch is done or ctx is cancelled.
+//
+// A worker registers as parked before checking for queued vertices, and push
+// queues a vertex before checking for parked workers, so a wake-up cannot be
+// missed.
+func (r *stealingRun) park(ctx context.Context) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.parked.Add(1)
+	defer r.parked.Add(-1)
+
+	for r.queued.Load() == 0 {
+		if r.pending.Load() == 0 || ctx.Err() != nil {
+			return false
+		}
+		r.wake.Wait()
+	}
+	return r.pending.Load() > 0 && ctx.Err() == nil
+}
+
+// outranksRunning reports whether priority is higher than that of a vertex
+// some worker is processing
+func (r *stealingRun) outranksRunning(priority float64) bool {
+	for w := range r.running {
+		if bits := r.running[w].Load(); bits != notRunning && priority > math.Float64frombits(bits) {
+			return true
+		}
+	}
+	return false
+}
+
+// wakeAll wakes every parked worker
+func (r *stealingRun) wakeAll() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.wake.Broadcast()
+}
+
+// steal tries every other worker's deque once, starting at a random victim
+func steal(deques []*workDeque, thief int, rng *rand.Rand) (int, bool) {
+	workers := len(deques)
+	if workers < 2 {
+		return 0, false
+	}
+
+	start := rng.Intn(workers)
+	for k := 0; k < workers; k++ {
+		victim := (start + k) % workers
+		if victim == thief {
+			continue
+		}
+		if i, ok := deques[victim].steal(); ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// workDeque is a fixed-capacity Chase-Lev work-stealing deque of batch
+// indices. Only the owning worker calls push and pop, which work at the
+// bottom; other workers call steal, which takes from the top with a CAS, so
+// no locks are needed.
+//
+// The capacity must be at least the number of items queued at once, which
+// for a batch is bounded by the batch size.
+type workDeque struct {
+	top    atomic.Int64
+	bottom atomic.Int64
+	items  []atomic.Int64
+}
+
+// newWorkDeque creates a deque holding up to capacity items
+func newWorkDeque(capacity int) *workDeque {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &workDeque{items: make([]atomic.Int64, capacity)}
+}
+
+// push adds an item at the bottom. Only the owner may call it.
+func (d *workDeque) push(item int) {
+	b := d.bottom.Load()
+	d.items[b%int64(len(d.items))].Store(int64(item))
+	d.bottom.Store(b + 1)
+}
+
+// pop removes the most recently pushed item. Only the owner may call it.
+func (d *workDeque) pop() (int, bool) {
+	b := d.bottom.Load() - 1
+	d.bottom.Store(b)
+	t := d.top.Load()
+	if t > b {
+		// Empty; undo the reservation
+		d.bottom.Store(b + 1)
+		return 0, false
+	}
+
+	item := d.items[b%int64(len(d.items))].Load()
+	if t == b {
+		// Last item: race thieves for it
+		won := d.top.CompareAndSwap(t, t+1)
+		d.bottom.Store(t + 1)
+		if !won {
+			return 0, false
+		}
+	}
+	return int(item), true
+}
+
+// steal removes the oldest item. It fails if the deque is empty or another
+// worker took the item first.
+func (d *workDeque) steal() (int, bool) {
+	t := d.top.Load()
+	b := d.bottom.Load()
+	if t >= b {
+		return 0, false
+	}
+
+	item := d.items[t%int64(len(d.items))].Load()
+	if !d.top.CompareAndSwap(t, t+1) {
+		return 0, false
+	}
+	return int(item), true
+}
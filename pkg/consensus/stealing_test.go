@@ -0,0 +1,270 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package consensus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/snow/consensus/avalanche"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkDequeEachItemTakenOnce(t *testing.T) {
+	const items = 10000
+	deque := newWorkDeque(items)
+
+	var (
+		lock  sync.Mutex
+		taken = make(map[int]int, items)
+		wg    sync.WaitGroup
+		done  = make(chan struct{})
+	)
+	take := func(item int) {
+		lock.Lock()
+		taken[item]++
+		lock.Unlock()
+	}
+
+	// Thieves steal from the top while the owner pushes and pops at the bottom
+	for thief := 0; thief < 3; thief++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if item, ok := deque.steal(); ok {
+					take(item)
+					continue
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}()
+	}
+
+	for item := 0; item < items; item++ {
+		deque.push(item)
+		if item%3 == 0 {
+			if popped, ok := deque.pop(); ok {
+				take(popped)
+			}
+		}
+	}
+	for {
+		item, ok := deque.pop()
+		if !ok {
+			break
+		}
+		take(item)
+	}
+	close(done)
+	wg.Wait()
+
+	require.Len(t, taken, items)
+	for item, count := range taken {
+		require.Equal(t, 1, count, "item %d", item)
+	}
+}
+
+func TestStealingRunParksIdleWorkers(t *testing.T) {
+	vertices := make([]avalanche.Vertex, 0, 3)
+	for i := 0; i < 3; i++ {
+		vertices = append(vertices, newTestVertex(nil, 1))
+	}
+
+	tests := []struct {
+		name string
+		wake func(run *stealingRun, cancel context.CancelFunc)
+		more bool
+	}{
+		{
+			name: "spare vertex queued",
+			wake: func(run *stealingRun, _ context.CancelFunc) {
+				run.push(0, 0)
+				run.push(0, 1)
+			},
+			more: true,
+		},
+		{
+			name: "batch done",
+			wake: func(run *stealingRun, _ context.CancelFunc) {
+				run.pending.Store(0)
+				run.wakeAll()
+			},
+		},
+		{
+			name: "cancelled",
+			wake: func(_ *stealingRun, cancel context.CancelFunc) {
+				cancel()
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			run := newStealingRun(newBatchSchedule(vertices), make([]float64, len(vertices)), 2)
+			run.pending.Store(1) // Another worker is still processing
+			stop := context.AfterFunc(ctx, run.wakeAll)
+			defer stop()
+
+			parked := make(chan bool)
+			go func() {
+				parked <- run.park(ctx)
+			}()
+			require.Eventually(t, func() bool { return run.parked.Load() == 1 }, time.Second, time.Millisecond)
+
+			// A single queued vertex is left to the worker that pushed it
+			if test.more {
+				run.push(1, 2)
+				select {
+				case <-parked:
+					t.Fatal("worker woke for a vertex its owner will take")
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+
+			test.wake(run, cancel)
+			select {
+			case more := <-parked:
+				assert.Equal(t, test.more, more)
+			case <-time.After(time.Second):
+				t.Fatal("parked worker was not woken")
+			}
+		})
+	}
+}
+
+func TestWorkStealingBalancesLoad(t *testing.T) {
+	// Every vertex has the same height, so all of them start on one worker
+	vertices := make([]avalanche.Vertex, 0, 40)
+	for i := 0; i < 40; i++ {
+		vertex := newTestVertex(nil, 4)
+		vertex.cost = time.Millisecond
+		vertices = append(vertices, vertex)
+	}
+
+	engine := newTestEngine(4, WorkStealingScheduler)
+	require.Empty(t, engine.ProcessVertices(context.Background(), vertices))
+
+	stats := engine.WorkerStats()
+	require.Len(t, stats, 4)
+
+	var processed, stolen uint64
+	busyWorkers := 0
+	for _, worker := range stats {
+		processed += worker.Processed
+		stolen += worker.Stolen
+		if worker.Processed > 0 {
+			busyWorkers++
+		}
+	}
+	assert.Equal(t, uint64(40), processed)
+	assert.Positive(t, stolen)
+	assert.Greater(t, busyWorkers, 1)
+}
+
+// skewedBatch builds one chain of chainLength dependent vertices and
+// independent unrelated vertices
+func skewedBatch(chainLength, independent int, cost time.Duration) []avalanche.Vertex {
+	vertices := make([]avalanche.Vertex, 0, chainLength+independent)
+
+	var parent *testVertex
+	for height := uint64(1); height <= uint64(chainLength); height++ {
+		var vertex *testVertex
+		if parent == nil {
+			vertex = newTestVertex(nil, height)
+		} else {
+			vertex = newTestVertex(nil, height, parent)
+		}
+		vertex.cost = cost
+		vertices = append(vertices, vertex)
+		parent = vertex
+	}
+	for i := 0; i < independent; i++ {
+		vertex := newTestVertex(nil, 1)
+		vertex.cost = cost
+		vertices = append(vertices, vertex)
+	}
+	return vertices
+}
+
+// processSkewedBatch processes a skewed batch on a fresh engine. The
+// baseline is the original engine's dispatch: BatchProcessVertices starts a
+// goroutine per vertex under a semaphore. It ignores parent-before-child
+// order, which only works in its favour.
+func processSkewedBatch(tb testing.TB, scheduler Scheduler, baseline bool) time.Duration {
+	vertices := skewedBatch(500, 10, 0)
+	engine := newTestEngine(4, scheduler)
+
+	start := time.Now()
+	if baseline {
+		require.NoError(tb, engine.BatchProcessVertices(context.Background(), vertices))
+		return time.Since(start)
+	}
+	errs := engine.ProcessVertices(context.Background(), vertices)
+	elapsed := time.Since(start)
+	require.Empty(tb, errs)
+	return elapsed
+}
+
+func benchmarkSkewedBatch(b *testing.B, scheduler Scheduler, baseline bool) {
+	var elapsed time.Duration
+	for i := 0; i < b.N; i++ {
+		elapsed += processSkewedBatch(b, scheduler, baseline)
+	}
+	b.ReportMetric(float64(elapsed.Nanoseconds())/float64(b.N), "batch-ns/op")
+}
+
+// timeSkewedBatch returns the mean time per skewed batch
+func timeSkewedBatch(t *testing.T, scheduler Scheduler, baseline bool, batches int) time.Duration {
+	var total time.Duration
+	for i := 0; i < batches; i++ {
+		total += processSkewedBatch(t, scheduler, baseline)
+	}
+	return total / time.Duration(batches)
+}
+
+// TestWorkStealingFasterOnSkewedBatch checks that keeping the chain on one
+// worker's deque beats the original engine's goroutine-per-vertex dispatch
+func TestWorkStealingFasterOnSkewedBatch(t *testing.T) {
+	if testing.Short() || raceEnabled {
+		t.Skip("Skipping scheduler comparison in short mode or with the race detector")
+	}
+
+	// The engines are timed in alternating rounds and the fastest round of
+	// each is compared, so a burst of load on the machine cannot decide it
+	var stealing, baseline time.Duration
+	for round := 0; round < 5; round++ {
+		if d := timeSkewedBatch(t, WorkStealingScheduler, false, 200); stealing == 0 || d < stealing {
+			stealing = d
+		}
+		if d := timeSkewedBatch(t, WorkStealingScheduler, true, 200); baseline == 0 || d < baseline {
+			baseline = d
+		}
+	}
+
+	speedup := float64(baseline) / float64(stealing)
+	t.Logf("Work stealing: %v/op, baseline: %v/op, speedup: %.2fx", stealing, baseline, speedup)
+	assert.GreaterOrEqual(t, speedup, 1.4)
+}
+
+func BenchmarkSkewedBatchWorkStealing(b *testing.B) {
+	benchmarkSkewedBatch(b, WorkStealingScheduler, false)
+}
+
+func BenchmarkSkewedBatchCentral(b *testing.B) {
+	benchmarkSkewedBatch(b, CentralScheduler, false)
+}
+
+func BenchmarkSkewedBatchBaseline(b *testing.B) {
+	benchmarkSkewedBatch(b, WorkStealingScheduler, true)
+}
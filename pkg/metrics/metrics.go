@@ -0,0 +1,128 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package metrics renders metrics in the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Type is the type of a metric family
+type Type string
+
+const (
+	// Counter is a value that only increases
+	Counter Type = "counter"
+
+	// Gauge is a value that can go up and down
+	Gauge Type = "gauge"
+
+	// Histogram counts observations in cumulative buckets
+	Histogram Type = "histogram"
+)
+
+// Sample is one value of a metric family
+type Sample struct {
+	Suffix string // Appended to the family name, such as _bucket for histograms
+	Labels map[string]string
+	Value  float64
+}
+
+// Bucket is a cumulative histogram bucket, counting observations less than
+// or equal to UpperBound
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Family is a named metric and its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// NewCounter returns a family with a single unlabelled counter sample
+func NewCounter(name, help string, value float64) Family {
+	return Family{Name: name, Help: help, Type: Counter, Samples: []Sample{{Value: value}}}
+}
+
+// NewGauge returns a family with a single unlabelled gauge sample
+func NewGauge(name, help string, value float64) Family {
+	return Family{Name: name, Help: help, Type: Gauge, Samples: []Sample{{Value: value}}}
+}
+
+// NewHistogram returns a histogram family from its cumulative buckets. The
+// +Inf bucket is added from count.
+func NewHistogram(name, help string, buckets []Bucket, count uint64, sum float64) Family {
+	samples := make([]Sample, 0, len(buckets)+3)
+	for _, bucket := range buckets {
+		samples = append(samples, Sample{
+			Suffix: "_bucket",
+			Labels: map[string]string{"le": formatValue(bucket.UpperBound)},
+			Value:  float64(bucket.Count),
+		})
+	}
+	samples = append(samples,
+		Sample{Suffix: "_bucket", Labels: map[string]string{"le": "+Inf"}, Value: float64(count)},
+		Sample{Suffix: "_sum", Value: sum},
+		Sample{Suffix: "_count", Value: float64(count)},
+	)
+	return Family{Name: name, Help: help, Type: Histogram, Samples: samples}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// Write renders the families in order. Labels are written sorted by name.
+func Write(w io.Writer, families ...Family) error {
+	buf := bufio.NewWriter(w)
+	for _, family := range families {
+		buf.WriteString("# HELP " + family.Name + " " + helpEscaper.Replace(family.Help) + "\n")
+		buf.WriteString("# TYPE " + family.Name + " " + string(family.Type) + "\n")
+		for _, sample := range family.Samples {
+			buf.WriteString(family.Name + sample.Suffix)
+			writeLabels(buf, sample.Labels)
+			buf.WriteString(" " + formatValue(sample.Value) + "\n")
+		}
+	}
+	return buf.Flush()
+}
+
+// formatValue formats a sample value or bucket bound
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// writeLabels writes a label set, if it is not empty
+func writeLabels(buf *bufio.Writer, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(name + `="` + labelEscaper.Replace(labels[name]) + `"`)
+	}
+	buf.WriteByte('}')
+}
@@ -0,0 +1,52 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, Write(&out,
+		NewCounter("requests_total", "Requests served", 42),
+		Family{
+			Name: "jobs",
+			Help: "Jobs by status\nincluding \\ finished ones",
+			Type: Gauge,
+			Samples: []Sample{
+				{Labels: map[string]string{"status": "queued", "pool": `a"b`}, Value: 3},
+				{Labels: map[string]string{"status": "done"}, Value: 0.5},
+			},
+		},
+	))
+
+	assert.Equal(t, `# HELP requests_total Requests served
+# TYPE requests_total counter
+requests_total 42
+# HELP jobs Jobs by status\nincluding \\ finished ones
+# TYPE jobs gauge
+jobs{pool="a\"b",status="queued"} 3
+jobs{status="done"} 0.5
+`, out.String())
+}
+
+func TestWriteHistogram(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, Write(&out, NewHistogram("job_duration_seconds", "Job durations",
+		[]Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 3}}, 4, 7.25)))
+
+	assert.Equal(t, `# HELP job_duration_seconds Job durations
+# TYPE job_duration_seconds histogram
+job_duration_seconds_bucket{le="0.1"} 1
+job_duration_seconds_bucket{le="1"} 3
+job_duration_seconds_bucket{le="+Inf"} 4
+job_duration_seconds_sum 7.25
+job_duration_seconds_count 4
+`, out.String())
+}
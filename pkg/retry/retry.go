@@ -0,0 +1,185 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package retry retries transient failures with exponential backoff
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of times an operation is tried
+	DefaultMaxAttempts = 3
+
+	// DefaultInitialDelay is the default delay before the first retry
+	DefaultInitialDelay = 100 * time.Millisecond
+
+	// DefaultMaxDelay is the default upper bound on the delay between attempts
+	DefaultMaxDelay = 5 * time.Second
+
+	// DefaultMultiplier is the default growth factor of the delay
+	DefaultMultiplier = 2.0
+
+	// DefaultJitter is the default fraction by which delays are randomized
+	DefaultJitter = 0.2
+)
+
+// config holds the retry policy
+type config struct {
+	maxAttempts  int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	multiplier   float64
+	jitter       float64
+	onRetry      func(attempt int, err error, delay time.Duration)
+}
+
+// Option is a function that configures the retry policy
+type Option func(*config)
+
+// MaxAttempts sets how many times the operation is tried, including the first attempt
+func MaxAttempts(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.maxAttempts = n
+		}
+	}
+}
+
+// InitialDelay sets the delay before the first retry
+func InitialDelay(delay time.Duration) Option {
+	return func(c *config) {
+		if delay >= 0 {
+			c.initialDelay = delay
+		}
+	}
+}
+
+// MaxDelay caps the delay between attempts
+func MaxDelay(delay time.Duration) Option {
+	return func(c *config) {
+		if delay >= 0 {
+			c.maxDelay = delay
+		}
+	}
+}
+
+// Multiplier sets the factor by which the delay grows after each retry
+func Multiplier(m float64) Option {
+	return func(c *config) {
+		if m >= 1 {
+			c.multiplier = m
+		}
+	}
+}
+
+// Jitter randomizes each delay by up to the given fraction in either
+// direction. A fraction of 0 disables jitter.
+func Jitter(fraction float64) Option {
+	return func(c *config) {
+		if fraction >= 0 && fraction <= 1 {
+			c.jitter = fraction
+		}
+	}
+}
+
+// OnRetry registers a callback invoked before each retry with the number of
+// the attempt that failed, its error and the delay before the next attempt
+func OnRetry(fn func(attempt int, err error, delay time.Duration)) Option {
+	return func(c *config) {
+		c.onRetry = fn
+	}
+}
+
+// permanentError marks an error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Do returns it immediately instead of retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls op until it succeeds, returns a permanent error, or the attempts
+// are exhausted, sleeping with exponential backoff and jitter between
+// attempts. It stops early when ctx is cancelled, returning the context
+// error.
+func Do(ctx context.Context, op func() error, opts ...Option) error {
+	c := &config{
+		maxAttempts:  DefaultMaxAttempts,
+		initialDelay: DefaultInitialDelay,
+		maxDelay:     DefaultMaxDelay,
+		multiplier:   DefaultMultiplier,
+		jitter:       DefaultJitter,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	delay := c.initialDelay
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := op()
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if attempt >= c.maxAttempts {
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+
+		wait := c.withJitter(delay)
+		if c.onRetry != nil {
+			c.onRetry(attempt, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+
+		delay = time.Duration(float64(delay) * c.multiplier)
+		if delay > c.maxDelay {
+			delay = c.maxDelay
+		}
+	}
+}
+
+// withJitter randomizes delay by up to the jitter fraction, capped at the maximum delay
+func (c *config) withJitter(delay time.Duration) time.Duration {
+	if delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+	if c.jitter == 0 || delay == 0 {
+		return delay
+	}
+
+	spread := float64(delay) * c.jitter
+	jittered := time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+	if jittered > c.maxDelay {
+		jittered = c.maxDelay
+	}
+	return jittered
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTransient = errors.New("transient failure")
+
+func TestDo(t *testing.T) {
+	errFatal := errors.New("fatal failure")
+
+	tests := []struct {
+		name         string
+		failures     int   // Number of leading attempts that fail
+		failWith     error // Error returned by failing attempts
+		maxAttempts  int
+		cancelAfter  int // Cancel the context once this many attempts ran (0 never cancels)
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "success on first attempt",
+			failures:     0,
+			maxAttempts:  3,
+			wantAttempts: 1,
+		},
+		{
+			name:         "success on second attempt",
+			failures:     1,
+			failWith:     errTransient,
+			maxAttempts:  3,
+			wantAttempts: 2,
+		},
+		{
+			name:         "exhausted retries",
+			failures:     5,
+			failWith:     errTransient,
+			maxAttempts:  3,
+			wantAttempts: 3,
+			wantErr:      errTransient,
+		},
+		{
+			name:         "permanent error is not retried",
+			failures:     5,
+			failWith:     Permanent(errFatal),
+			maxAttempts:  3,
+			wantAttempts: 1,
+			wantErr:      errFatal,
+		},
+		{
+			name:         "context cancelled mid-retry",
+			failures:     5,
+			failWith:     errTransient,
+			maxAttempts:  5,
+			cancelAfter:  2,
+			wantAttempts: 2,
+			wantErr:      context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			attempts := 0
+			op := func() error {
+				attempts++
+				if attempts == tt.cancelAfter {
+					cancel()
+				}
+				if attempts <= tt.failures {
+					return tt.failWith
+				}
+				return nil
+			}
+
+			err := Do(ctx, op, MaxAttempts(tt.maxAttempts), InitialDelay(time.Millisecond), Jitter(0))
+			assert.Equal(t, tt.wantAttempts, attempts)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDoBackoff(t *testing.T) {
+	delays := make([]time.Duration, 0)
+	err := Do(context.Background(), func() error { return errTransient },
+		MaxAttempts(5),
+		InitialDelay(time.Millisecond),
+		MaxDelay(3*time.Millisecond),
+		Multiplier(2),
+		Jitter(0),
+		OnRetry(func(attempt int, err error, delay time.Duration) {
+			assert.ErrorIs(t, err, errTransient)
+			delays = append(delays, delay)
+		}),
+	)
+	require.ErrorIs(t, err, errTransient)
+
+	// The delay doubles until it reaches the cap
+	want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, 3 * time.Millisecond}
+	assert.Equal(t, want, delays)
+}
+
+func TestDoJitterStaysInRange(t *testing.T) {
+	c := &config{maxDelay: time.Second, jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		delay := c.withJitter(100 * time.Millisecond)
+		assert.GreaterOrEqual(t, delay, 50*time.Millisecond)
+		assert.LessOrEqual(t, delay, 150*time.Millisecond)
+	}
+}
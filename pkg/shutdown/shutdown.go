@@ -0,0 +1,75 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package shutdown coordinates stopping the background loops of a service
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned when background loops do not exit before the deadline
+var ErrTimeout = errors.New("timed out waiting for background loops to exit")
+
+// Group runs background loops that share one cancellation context.
+//
+// Loops must return once the context passed to them is done. Work that is
+// already in progress when the context is cancelled should be finished or
+// rolled back before the loop returns, since Shutdown waits for it.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewGroup creates a group whose context is cancelled when parent is done or
+// when Shutdown is called
+func NewGroup(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+	return &Group{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Context returns the context shared by the loops of the group
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Go runs loop in a new goroutine tracked by the group
+func (g *Group) Go(loop func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		loop(g.ctx)
+	}()
+}
+
+// Wait waits up to timeout for all loops to return without cancelling them
+func (g *Group) Wait(timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrTimeout
+	}
+}
+
+// Shutdown cancels the loops and waits up to timeout for them to return
+func (g *Group) Shutdown(timeout time.Duration) error {
+	g.cancel()
+	return g.Wait(timeout)
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package shutdown
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownStopsLoops(t *testing.T) {
+	group := NewGroup(context.Background())
+
+	exited := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		i := i
+		group.Go(func(ctx context.Context) {
+			ticker := time.NewTicker(time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					exited <- i
+					return
+				case <-ticker.C:
+				}
+			}
+		})
+	}
+
+	require.NoError(t, group.Shutdown(time.Second))
+	assert.Len(t, exited, 3)
+	assert.ErrorIs(t, group.Context().Err(), context.Canceled)
+}
+
+func TestShutdownWaitsForWorkInProgress(t *testing.T) {
+	group := NewGroup(context.Background())
+
+	started := make(chan struct{})
+	var finished int32
+	group.Go(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+
+		// Work in progress completes before the loop returns
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+	})
+
+	<-started
+	require.NoError(t, group.Shutdown(time.Second))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&finished))
+}
+
+func TestShutdownTimesOut(t *testing.T) {
+	group := NewGroup(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	group.Go(func(ctx context.Context) {
+		<-release // Ignores cancellation
+	})
+
+	assert.ErrorIs(t, group.Shutdown(10*time.Millisecond), ErrTimeout)
+}
+
+func TestParentCancellationStopsLoops(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	group := NewGroup(parent)
+
+	exited := make(chan struct{})
+	group.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(exited)
+	})
+
+	cancel()
+	require.NoError(t, group.Wait(time.Second))
+	<-exited
+}
+
+func TestWaitDoesNotCancel(t *testing.T) {
+	group := NewGroup(context.Background())
+
+	release := make(chan struct{})
+	group.Go(func(ctx context.Context) {
+		<-release
+	})
+
+	assert.ErrorIs(t, group.Wait(10*time.Millisecond), ErrTimeout)
+	assert.NoError(t, group.Context().Err())
+
+	close(release)
+	assert.NoError(t, group.Wait(time.Second))
+}
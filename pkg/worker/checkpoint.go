@@ -0,0 +1,381 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultSegmentSize is the number of payload bytes processed as one segment
+	DefaultSegmentSize = 4096
+
+	// DefaultFlushInterval is the number of completed segments buffered
+	// before they are written to the checkpoint store
+	DefaultFlushInterval = 16
+)
+
+// CheckpointConfig controls how jobs are segmented and checkpointed
+type CheckpointConfig struct {
+	SegmentSize   int // Payload bytes per segment
+	FlushInterval int // Completed segments per checkpoint write
+}
+
+// JobProgress reports how far a checkpointed job has progressed
+type JobProgress struct {
+	SegmentsDone          int  `json:"segments_done"`
+	SegmentsTotal         int  `json:"segments_total"`
+	ResumedFromCheckpoint bool `json:"resumed_from_checkpoint"`
+}
+
+// CheckpointStore persists completed segment results keyed by job
+type CheckpointStore interface {
+	// Load returns the recorded segment results for a job by segment index
+	Load(jobKey string) (map[int][]byte, error)
+
+	// Save records additional completed segment results for a job
+	Save(jobKey string, segments map[int][]byte) error
+
+	// Delete removes all checkpoint data for a job
+	Delete(jobKey string) error
+}
+
+// SegmentProcessor processes a single segment of a job payload
+type SegmentProcessor func(ctx context.Context, index int, segment []byte) ([]byte, error)
+
+// JobKey returns the key identifying a job across restarts. Segment results
+// are only valid for the segment size they were computed with, so the size
+// is part of the key and a worker restarted with another size starts over.
+func JobKey(payload []byte, segmentSize int) string {
+	hash := sha256.New()
+	_ = binary.Write(hash, binary.BigEndian, uint64(segmentSize))
+	hash.Write(payload)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory. It survives a job being
+// cancelled and resubmitted, but not a process restart.
+type MemoryCheckpointStore struct {
+	lock sync.Mutex
+	jobs map[string]map[int][]byte
+}
+
+// NewMemoryCheckpointStore creates a new in-memory checkpoint store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		jobs: make(map[string]map[int][]byte),
+	}
+}
+
+// Load implements CheckpointStore
+func (s *MemoryCheckpointStore) Load(jobKey string) (map[int][]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	segments := make(map[int][]byte, len(s.jobs[jobKey]))
+	for index, output := range s.jobs[jobKey] {
+		segments[index] = output
+	}
+	return segments, nil
+}
+
+// Save implements CheckpointStore
+func (s *MemoryCheckpointStore) Save(jobKey string, segments map[int][]byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, exists := s.jobs[jobKey]; !exists {
+		s.jobs[jobKey] = make(map[int][]byte, len(segments))
+	}
+	for index, output := range segments {
+		s.jobs[jobKey][index] = output
+	}
+	return nil
+}
+
+// Delete implements CheckpointStore
+func (s *MemoryCheckpointStore) Delete(jobKey string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.jobs, jobKey)
+	return nil
+}
+
+// FileCheckpointStore keeps one checkpoint file per job in a local directory
+// so that completed segments survive a worker restart
+type FileCheckpointStore struct {
+	lock sync.Mutex
+	dir  string
+}
+
+// NewFileCheckpointStore creates a checkpoint store rooted at dir
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// Load implements CheckpointStore
+func (s *FileCheckpointStore) Load(jobKey string) (map[int][]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.read(jobKey)
+}
+
+// Save implements CheckpointStore
+func (s *FileCheckpointStore) Save(jobKey string, segments map[int][]byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	existing, err := s.read(jobKey)
+	if err != nil {
+		return err
+	}
+	for index, output := range segments {
+		existing[index] = output
+	}
+
+	data, err := json.Marshal(existing)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn checkpoint
+	tmpPath := s.path(jobKey) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path(jobKey)); err != nil {
+		return fmt.Errorf("failed to commit checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Delete implements CheckpointStore
+func (s *FileCheckpointStore) Delete(jobKey string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := os.Remove(s.path(jobKey)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete checkpoint: %w", err)
+	}
+	return nil
+}
+
+// read loads the checkpoint file for a job. The caller must hold the lock.
+func (s *FileCheckpointStore) read(jobKey string) (map[int][]byte, error) {
+	data, err := os.ReadFile(s.path(jobKey))
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[int][]byte), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	segments := make(map[int][]byte)
+	if err := json.Unmarshal(data, &segments); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return segments, nil
+}
+
+// path returns the checkpoint file path for a job
+func (s *FileCheckpointStore) path(jobKey string) string {
+	return filepath.Join(s.dir, jobKey+".ckpt")
+}
+
+// CheckpointedWorker implements the Worker interface for large jobs. The task
+// payload is split into segments and completed segments are checkpointed, so
+// resubmitting the same payload after a restart skips work already done.
+type CheckpointedWorker struct {
+	id      string
+	logger  logging.Logger
+	store   CheckpointStore
+	config  CheckpointConfig
+	process SegmentProcessor
+
+	lock     sync.RWMutex
+	progress map[string]JobProgress // In-flight progress by task ID
+}
+
+// NewCheckpointedWorker creates a new checkpointed worker
+func NewCheckpointedWorker(id string, logger logging.Logger, store CheckpointStore, config CheckpointConfig, process SegmentProcessor) *CheckpointedWorker {
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = DefaultSegmentSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultFlushInterval
+	}
+	if process == nil {
+		process = hashSegment
+	}
+
+	return &CheckpointedWorker{
+		id:       id,
+		logger:   logger,
+		store:    store,
+		config:   config,
+		process:  process,
+		progress: make(map[string]JobProgress),
+	}
+}
+
+// ProcessTask handles the processing of a task
+func (w *CheckpointedWorker) ProcessTask(ctx context.Context, task Task) (Result, error) {
+	output, progress, err := w.runJob(ctx, task.ID, task.Payload)
+
+	w.lock.Lock()
+	delete(w.progress, task.ID)
+	w.lock.Unlock()
+
+	result := Result{
+		TaskID:    task.ID,
+		Output:    output,
+		StartTime: task.StartTime,
+		EndTime:   time.Now(),
+		Progress:  &progress,
+	}
+
+	return result, err
+}
+
+// Progress returns the progress of an in-flight task
+func (w *CheckpointedWorker) Progress(taskID string) (JobProgress, bool) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	progress, found := w.progress[taskID]
+	return progress, found
+}
+
+// runJob processes the segments of a payload that have not been checkpointed
+func (w *CheckpointedWorker) runJob(ctx context.Context, taskID string, payload []byte) ([]byte, JobProgress, error) {
+	jobKey := JobKey(payload, w.config.SegmentSize)
+	segments := splitSegments(payload, w.config.SegmentSize)
+	progress := JobProgress{SegmentsTotal: len(segments)}
+
+	checkpointed, err := w.store.Load(jobKey)
+	if err != nil {
+		return nil, progress, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	outputs := make([][]byte, len(segments))
+	done := make([]bool, len(segments))
+	for index, output := range checkpointed {
+		if index < 0 || index >= len(segments) {
+			continue
+		}
+		outputs[index] = output
+		done[index] = true
+		progress.SegmentsDone++
+	}
+	progress.ResumedFromCheckpoint = progress.SegmentsDone > 0
+	w.setProgress(taskID, progress)
+
+	if progress.ResumedFromCheckpoint {
+		w.logger.Info("Resuming job from checkpoint",
+			zap.String("taskID", taskID),
+			zap.String("jobKey", jobKey),
+			zap.Int("segmentsDone", progress.SegmentsDone),
+			zap.Int("segmentsTotal", progress.SegmentsTotal))
+	}
+
+	// Completed segments are buffered and written in batches so checkpointing
+	// does not dominate the runtime of small segments
+	pending := make(map[int][]byte, w.config.FlushInterval)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if err := w.store.Save(jobKey, pending); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+		pending = make(map[int][]byte, w.config.FlushInterval)
+		return nil
+	}
+
+	for index, segment := range segments {
+		if done[index] {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			if flushErr := flush(); flushErr != nil {
+				w.logger.Error("Failed to checkpoint cancelled job", zap.String("taskID", taskID), zap.Error(flushErr))
+			}
+			return nil, progress, err
+		}
+
+		output, err := w.process(ctx, index, segment)
+		if err != nil {
+			if flushErr := flush(); flushErr != nil {
+				w.logger.Error("Failed to checkpoint failed job", zap.String("taskID", taskID), zap.Error(flushErr))
+			}
+			return nil, progress, fmt.Errorf("segment %d failed: %w", index, err)
+		}
+
+		outputs[index] = output
+		done[index] = true
+		pending[index] = output
+		progress.SegmentsDone++
+		w.setProgress(taskID, progress)
+
+		if len(pending) >= w.config.FlushInterval {
+			if err := flush(); err != nil {
+				return nil, progress, err
+			}
+		}
+	}
+
+	// The job is complete, so its checkpoint is no longer needed
+	if err := w.store.Delete(jobKey); err != nil {
+		w.logger.Warn("Failed to delete checkpoint", zap.String("jobKey", jobKey), zap.Error(err))
+	}
+
+	return bytes.Join(outputs, nil), progress, nil
+}
+
+// setProgress records the progress of an in-flight task
+func (w *CheckpointedWorker) setProgress(taskID string, progress JobProgress) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.progress[taskID] = progress
+}
+
+// splitSegments splits a payload into segments of at most size bytes
+func splitSegments(payload []byte, size int) [][]byte {
+	segments := make([][]byte, 0, (len(payload)+size-1)/size)
+	for start := 0; start < len(payload); start += size {
+		end := start + size
+		if end > len(payload) {
+			end = len(payload)
+		}
+		segments = append(segments, payload[start:end])
+	}
+	return segments
+}
+
+// hashSegment is the default segment processor, producing a digest per segment
+func hashSegment(_ context.Context, _ int, segment []byte) ([]byte, error) {
+	sum := sha256.Sum256(segment)
+	return sum[:], nil
+}
@@ -0,0 +1,177 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package worker
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore wraps a CheckpointStore and counts writes
+type countingStore struct {
+	CheckpointStore
+	saves int32
+}
+
+func (s *countingStore) Save(jobKey string, segments map[int][]byte) error {
+	atomic.AddInt32(&s.saves, 1)
+	return s.CheckpointStore.Save(jobKey, segments)
+}
+
+func testPayload(segments, segmentSize int) []byte {
+	payload := make([]byte, segments*segmentSize)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	return payload
+}
+
+func TestCheckpointedWorkerResumesAfterCancellation(t *testing.T) {
+	config := CheckpointConfig{SegmentSize: 16, FlushInterval: 4}
+	payload := testPayload(64, config.SegmentSize)
+
+	// Reference output from an uninterrupted run
+	reference := NewCheckpointedWorker("reference", logging.NoLog{}, NewMemoryCheckpointStore(), config, nil)
+	expected, err := reference.ProcessTask(context.Background(), Task{ID: "reference", Payload: payload})
+	require.NoError(t, err)
+	require.False(t, expected.Progress.ResumedFromCheckpoint)
+
+	store := NewMemoryCheckpointStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var firstRunCalls int32
+	interrupted := NewCheckpointedWorker("worker-0", logging.NoLog{}, store, config,
+		func(ctx context.Context, index int, segment []byte) ([]byte, error) {
+			if atomic.AddInt32(&firstRunCalls, 1) == 22 {
+				cancel() // Simulate the worker being stopped mid-job
+			}
+			return hashSegment(ctx, index, segment)
+		})
+	result, err := interrupted.ProcessTask(ctx, Task{ID: "task-1", Payload: payload})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 22, result.Progress.SegmentsDone)
+
+	// A fresh worker sharing the store picks the job up from the checkpoint
+	var secondRunCalls int32
+	resumed := NewCheckpointedWorker("worker-1", logging.NoLog{}, store, config,
+		func(ctx context.Context, index int, segment []byte) ([]byte, error) {
+			atomic.AddInt32(&secondRunCalls, 1)
+			return hashSegment(ctx, index, segment)
+		})
+	result, err = resumed.ProcessTask(context.Background(), Task{ID: "task-1", Payload: payload})
+	require.NoError(t, err)
+
+	assert.True(t, result.Progress.ResumedFromCheckpoint)
+	assert.Equal(t, 64, result.Progress.SegmentsDone)
+	assert.Equal(t, 64, result.Progress.SegmentsTotal)
+	assert.Equal(t, int32(64-22), secondRunCalls)
+	assert.Less(t, secondRunCalls, int32(64))
+	assert.True(t, bytes.Equal(expected.Output, result.Output))
+
+	// Completed jobs leave no checkpoint behind
+	remaining, err := store.Load(JobKey(payload, config.SegmentSize))
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
+
+func TestCheckpointedWorkerBatchesWrites(t *testing.T) {
+	config := CheckpointConfig{SegmentSize: 8, FlushInterval: 16}
+	store := &countingStore{CheckpointStore: NewMemoryCheckpointStore()}
+	worker := NewCheckpointedWorker("worker-0", logging.NoLog{}, store, config, nil)
+
+	result, err := worker.ProcessTask(context.Background(), Task{ID: "task-1", Payload: testPayload(64, config.SegmentSize)})
+	require.NoError(t, err)
+	assert.Equal(t, 64, result.Progress.SegmentsDone)
+	assert.Equal(t, int32(64/16), atomic.LoadInt32(&store.saves))
+}
+
+func TestFileCheckpointStoreSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	config := CheckpointConfig{SegmentSize: 4, FlushInterval: 1}
+	payload := testPayload(10, config.SegmentSize)
+
+	store, err := NewFileCheckpointStore(dir)
+	require.NoError(t, err)
+
+	failing := NewCheckpointedWorker("worker-0", logging.NoLog{}, store, config,
+		func(ctx context.Context, index int, segment []byte) ([]byte, error) {
+			if index == 6 {
+				return nil, context.DeadlineExceeded
+			}
+			return hashSegment(ctx, index, segment)
+		})
+	_, err = failing.ProcessTask(context.Background(), Task{ID: "task-1", Payload: payload, StartTime: time.Now()})
+	require.Error(t, err)
+
+	// Reopen the store as a restarted worker would
+	reopened, err := NewFileCheckpointStore(dir)
+	require.NoError(t, err)
+	segments, err := reopened.Load(JobKey(payload, config.SegmentSize))
+	require.NoError(t, err)
+	assert.Len(t, segments, 6)
+
+	resumed := NewCheckpointedWorker("worker-1", logging.NoLog{}, reopened, config, nil)
+	result, err := resumed.ProcessTask(context.Background(), Task{ID: "task-1", Payload: payload})
+	require.NoError(t, err)
+	assert.True(t, result.Progress.ResumedFromCheckpoint)
+	assert.Equal(t, 10, result.Progress.SegmentsDone)
+
+	require.NoError(t, reopened.Delete(JobKey(payload, config.SegmentSize)))
+	segments, err = reopened.Load(JobKey(payload, config.SegmentSize))
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+}
+
+func TestCheckpointIgnoredAfterSegmentSizeChange(t *testing.T) {
+	dir := t.TempDir()
+	config := CheckpointConfig{SegmentSize: 4, FlushInterval: 1}
+	payload := testPayload(10, config.SegmentSize)
+
+	store, err := NewFileCheckpointStore(dir)
+	require.NoError(t, err)
+	failing := NewCheckpointedWorker("worker-0", logging.NoLog{}, store, config,
+		func(ctx context.Context, index int, segment []byte) ([]byte, error) {
+			if index == 6 {
+				return nil, context.DeadlineExceeded
+			}
+			return hashSegment(ctx, index, segment)
+		})
+	_, err = failing.ProcessTask(context.Background(), Task{ID: "task-1", Payload: payload})
+	require.Error(t, err)
+
+	// The worker restarts with segments twice as large
+	resized := CheckpointConfig{SegmentSize: 8, FlushInterval: 1}
+	reference := NewCheckpointedWorker("reference", logging.NoLog{}, NewMemoryCheckpointStore(), resized, nil)
+	expected, err := reference.ProcessTask(context.Background(), Task{ID: "reference", Payload: payload})
+	require.NoError(t, err)
+
+	reopened, err := NewFileCheckpointStore(dir)
+	require.NoError(t, err)
+	var calls int32
+	restarted := NewCheckpointedWorker("worker-1", logging.NoLog{}, reopened, resized,
+		func(ctx context.Context, index int, segment []byte) ([]byte, error) {
+			atomic.AddInt32(&calls, 1)
+			return hashSegment(ctx, index, segment)
+		})
+	result, err := restarted.ProcessTask(context.Background(), Task{ID: "task-1", Payload: payload})
+	require.NoError(t, err)
+
+	assert.False(t, result.Progress.ResumedFromCheckpoint)
+	assert.Equal(t, 5, result.Progress.SegmentsTotal)
+	assert.Equal(t, int32(5), calls)
+	assert.True(t, bytes.Equal(expected.Output, result.Output))
+
+	// The checkpoint of the old size is still there for a worker using it
+	segments, err := reopened.Load(JobKey(payload, config.SegmentSize))
+	require.NoError(t, err)
+	assert.Len(t, segments, 6)
+}
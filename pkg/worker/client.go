@@ -10,17 +10,24 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/metrics"
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/retry"
 	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 // Client provides communication with worker services
 type Client struct {
-	baseURL     string
-	httpClient  *http.Client
-	logger      logging.Logger
-	concurrency int
+	baseURL       string
+	httpClient    *http.Client
+	logger        logging.Logger
+	concurrency   int
+	retryOptions  []retry.Option
+	retryAttempts uint64 // Requests retried after a transient failure
 }
 
 // ClientOption is a function that configures a Client
@@ -42,6 +49,14 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// WithRetry sets the retry policy for requests that fail with a connection
+// error or a 502/503 response
+func WithRetry(options ...retry.Option) ClientOption {
+	return func(c *Client) {
+		c.retryOptions = options
+	}
+}
+
 // NewClient creates a new worker client
 func NewClient(baseURL string, logger logging.Logger, options ...ClientOption) *Client {
 	client := &Client{
@@ -63,6 +78,7 @@ func NewClient(baseURL string, logger logging.Logger, options ...ClientOption) *
 
 // TaskRequest represents a task submission request
 type TaskRequest struct {
+	TaskID  string `json:"task_id,omitempty"` // Optional, generated by the server when empty
 	Payload []byte `json:"payload"`
 }
 
@@ -74,7 +90,9 @@ type TaskResponse struct {
 
 // SubmitTask submits a task to the worker service
 func (c *Client) SubmitTask(ctx context.Context, payload []byte) (string, error) {
+	// The task ID is chosen here so a retried submission is deduplicated by the server
 	reqData := TaskRequest{
+		TaskID:  uuid.New().String(),
 		Payload: payload,
 	}
 
@@ -83,19 +101,21 @@ func (c *Client) SubmitTask(ctx context.Context, payload []byte) (string, error)
 		return "", fmt.Errorf("failed to marshal task request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/tasks", strings.NewReader(string(reqBody)))
-	if err != nil {
-		return "", fmt.Errorf("failed to create task request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/tasks", strings.NewReader(string(reqBody)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create task request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to submit task: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusAccepted {
+	// A retry of a submission that already reached the server reports the existing task
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return "", fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
 	}
@@ -110,12 +130,13 @@ func (c *Client) SubmitTask(ctx context.Context, payload []byte) (string, error)
 
 // GetTaskResult retrieves the result of a task
 func (c *Client) GetTaskResult(ctx context.Context, taskID string) (*Result, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/tasks/%s", c.baseURL, taskID), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create result request: %w", err)
-	}
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/tasks/%s", c.baseURL, taskID), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create result request: %w", err)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get task result: %w", err)
 	}
@@ -140,12 +161,13 @@ func (c *Client) GetTaskResult(ctx context.Context, taskID string) (*Result, err
 
 // Health checks the health of the worker service
 func (c *Client) Health(ctx context.Context) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/health", nil)
-	if err != nil {
-		return fmt.Errorf("failed to create health request: %w", err)
-	}
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/health", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create health request: %w", err)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to check health: %w", err)
 	}
@@ -156,4 +178,54 @@ func (c *Client) Health(ctx context.Context) error {
 	}
 
 	return nil
-} 
+}
+
+// RetryAttempts returns how many requests were retried after a transient failure
+func (c *Client) RetryAttempts() uint64 {
+	return atomic.LoadUint64(&c.retryAttempts)
+}
+
+// WriteMetrics writes the client's retry counter in the Prometheus text
+// format, for callers that expose it alongside the worker's job metrics
+func (c *Client) WriteMetrics(w io.Writer) error {
+	return metrics.Write(w,
+		metrics.NewCounter("retry_attempts_total", "Requests retried after a transient failure", float64(c.RetryAttempts())),
+	)
+}
+
+// do sends the request built by newRequest, retrying connection errors and
+// 502/503 responses according to the client's retry policy
+func (c *Client) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	var resp *http.Response
+	op := func() error {
+		req, err := newRequest()
+		if err != nil {
+			return retry.Permanent(err)
+		}
+
+		resp, err = c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+		}
+		return nil
+	}
+
+	options := append([]retry.Option{}, c.retryOptions...)
+	options = append(options, retry.OnRetry(func(attempt int, err error, delay time.Duration) {
+		atomic.AddUint64(&c.retryAttempts, 1)
+		c.logger.Debug("Retrying worker request",
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+	}))
+
+	if err := retry.Do(ctx, op, options...); err != nil {
+		return nil, err
+	}
+	return resp, nil
+} 
\ No newline at end of file
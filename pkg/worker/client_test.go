@@ -0,0 +1,81 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/retry"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRetryingClient(url string) *Client {
+	return NewClient(url, logging.NoLog{}, WithRetry(retry.MaxAttempts(3), retry.InitialDelay(time.Millisecond)))
+}
+
+func TestClientRetriesUnavailableBackend(t *testing.T) {
+	var calls int32
+	taskIDs := make(chan string, 2)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req TaskRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		taskIDs <- req.TaskID
+
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(TaskResponse{TaskID: req.TaskID, Status: "accepted"})
+	}))
+	defer backend.Close()
+
+	client := newRetryingClient(backend.URL)
+	taskID, err := client.SubmitTask(context.Background(), []byte("payload"))
+	require.NoError(t, err)
+
+	// Both attempts carry the same task ID, so the server can deduplicate them
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, taskID, <-taskIDs)
+	assert.Equal(t, taskID, <-taskIDs)
+	assert.Equal(t, uint64(1), client.RetryAttempts())
+}
+
+func TestClientDoesNotRetryServerErrors(t *testing.T) {
+	var calls int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	client := newRetryingClient(backend.URL)
+	assert.Error(t, client.Health(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Zero(t, client.RetryAttempts())
+}
+
+func TestClientRetriesConnectionErrors(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	url := backend.URL
+	backend.Close()
+
+	client := newRetryingClient(url)
+	_, err := client.GetTaskResult(context.Background(), "task-0")
+	assert.Error(t, err)
+	assert.Equal(t, uint64(2), client.RetryAttempts())
+
+	var out bytes.Buffer
+	require.NoError(t, client.WriteMetrics(&out))
+	assert.Contains(t, out.String(), "# TYPE retry_attempts_total counter\nretry_attempts_total 2\n")
+}
@@ -0,0 +1,366 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package worker
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/metrics"
+)
+
+// DefaultJobTTL is how long finished jobs are retained in the registry
+const DefaultJobTTL = 10 * time.Minute
+
+var (
+	// ErrJobNotFound is returned for jobs that are unknown or have expired
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrJobRunning is returned when deleting a job that is being processed
+	ErrJobRunning = errors.New("job is running")
+
+	// jobDurationBuckets are the upper bounds, in seconds, of the job duration histogram
+	jobDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// JobStatus is the lifecycle state of a job
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Job is the registry record of a submitted task
+type Job struct {
+	ID          string       `json:"id"`
+	Status      JobStatus    `json:"status"`
+	SubmittedAt time.Time    `json:"submitted_at"`
+	StartedAt   *time.Time   `json:"started_at,omitempty"`
+	FinishedAt  *time.Time   `json:"finished_at,omitempty"`
+	Output      []byte       `json:"output,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Progress    *JobProgress `json:"progress,omitempty"`
+
+	result     Result
+	generation uint64 // Distinguishes registrations that reuse an ID
+}
+
+// finished reports whether the job has reached a terminal state
+func (j *Job) finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed
+}
+
+// HistogramBucket counts observations less than or equal to UpperBound
+type HistogramBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// DurationHistogram is a cumulative histogram of job durations in seconds
+type DurationHistogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	Count   uint64            `json:"count"`
+	Sum     float64           `json:"sum"`
+}
+
+// JobStats summarizes the jobs held by a registry
+type JobStats struct {
+	QueueDepth int               `json:"queue_depth"`
+	Jobs       map[JobStatus]int `json:"jobs"`
+	Duration   DurationHistogram `json:"duration_seconds"`
+}
+
+// JobRegistry tracks the status, timing and result of every submitted job.
+// Finished jobs are removed once they are older than the TTL.
+type JobRegistry struct {
+	lock        sync.Mutex
+	jobs        map[string]*Job
+	ttl         time.Duration
+	lastPrune   time.Time
+	durations   DurationHistogram
+	generations uint64 // Registrations so far
+}
+
+// NewJobRegistry creates a registry that retains finished jobs for ttl
+func NewJobRegistry(ttl time.Duration) *JobRegistry {
+	if ttl <= 0 {
+		ttl = DefaultJobTTL
+	}
+
+	buckets := make([]HistogramBucket, len(jobDurationBuckets))
+	for i, bound := range jobDurationBuckets {
+		buckets[i].UpperBound = bound
+	}
+
+	return &JobRegistry{
+		jobs:      make(map[string]*Job),
+		ttl:       ttl,
+		lastPrune: time.Now(),
+		durations: DurationHistogram{Buckets: buckets},
+	}
+}
+
+// SetTTL changes how long finished jobs are retained
+func (r *JobRegistry) SetTTL(ttl time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if ttl > 0 {
+		r.ttl = ttl
+	}
+}
+
+// Register records a new queued job. If a job with the same ID already
+// exists, its record is returned and created is false. Expired jobs that
+// have not been pruned yet count as absent.
+func (r *JobRegistry) Register(id string, submittedAt time.Time) (job Job, created bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	r.pruneIfDue(now)
+	if existing, exists := r.jobs[id]; exists {
+		if !r.expired(existing, now) {
+			return *existing, false
+		}
+		delete(r.jobs, id)
+	}
+
+	r.generations++
+	record := &Job{
+		ID:          id,
+		Status:      JobQueued,
+		SubmittedAt: submittedAt,
+		generation:  r.generations,
+	}
+	r.jobs[id] = record
+	return *record, true
+}
+
+// Unregister removes a job that was registered but never queued. It only
+// removes the registration of the given generation while it is still queued.
+func (r *JobRegistry) Unregister(id string, generation uint64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if job, exists := r.jobs[id]; exists && job.generation == generation && job.Status == JobQueued {
+		delete(r.jobs, id)
+	}
+}
+
+// Start marks a queued job as running. It returns false if the registration
+// of the given generation is no longer queued, in which case the task should
+// not be processed: the job was deleted while queued, or it was deleted and
+// resubmitted and the task belongs to the earlier registration.
+func (r *JobRegistry) Start(id string, generation uint64) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	job, exists := r.jobs[id]
+	if !exists || job.generation != generation || job.Status != JobQueued {
+		return false
+	}
+
+	now := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &now
+	return true
+}
+
+// Finish records the result of a job
+func (r *JobRegistry) Finish(result Result) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	job, exists := r.jobs[result.TaskID]
+	if !exists {
+		return
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Output = result.Output
+	job.Progress = result.Progress
+	job.result = result
+	if result.Error != nil {
+		job.Status = JobFailed
+		job.Error = result.Error.Error()
+	} else {
+		job.Status = JobDone
+	}
+
+	if job.StartedAt != nil {
+		r.observeDuration(now.Sub(*job.StartedAt))
+	}
+}
+
+// Get returns the record of a job
+func (r *JobRegistry) Get(id string) (Job, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.pruneIfDue(time.Now())
+	job, exists := r.jobs[id]
+	if !exists || r.expired(job, time.Now()) {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns the jobs with the given status, or all jobs if status is
+// empty, ordered by submission time
+func (r *JobRegistry) List(status JobStatus) []Job {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	r.pruneIfDue(now)
+
+	jobs := make([]Job, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		if r.expired(job, now) || (status != "" && job.Status != status) {
+			continue
+		}
+		jobs = append(jobs, *job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].SubmittedAt.Equal(jobs[j].SubmittedAt) {
+			return jobs[i].SubmittedAt.Before(jobs[j].SubmittedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+// Delete removes a job. Queued jobs are dropped before they run; running
+// jobs cannot be deleted.
+func (r *JobRegistry) Delete(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	job, exists := r.jobs[id]
+	if !exists || r.expired(job, time.Now()) {
+		return ErrJobNotFound
+	}
+	if job.Status == JobRunning {
+		return ErrJobRunning
+	}
+
+	delete(r.jobs, id)
+	return nil
+}
+
+// Prune removes finished jobs older than the TTL and returns how many were removed
+func (r *JobRegistry) Prune(now time.Time) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.prune(now)
+}
+
+// Stats returns the job counts by status and the job duration histogram
+func (r *JobRegistry) Stats() JobStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	r.pruneIfDue(now)
+
+	stats := JobStats{
+		Jobs: map[JobStatus]int{
+			JobQueued:  0,
+			JobRunning: 0,
+			JobDone:    0,
+			JobFailed:  0,
+		},
+		Duration: DurationHistogram{
+			Buckets: append([]HistogramBucket(nil), r.durations.Buckets...),
+			Count:   r.durations.Count,
+			Sum:     r.durations.Sum,
+		},
+	}
+	for _, job := range r.jobs {
+		if !r.expired(job, now) {
+			stats.Jobs[job.Status]++
+		}
+	}
+	stats.QueueDepth = stats.Jobs[JobQueued]
+	return stats
+}
+
+// WriteMetrics writes the queue depth, the job counts by status and the job
+// duration histogram in the Prometheus text format
+func (r *JobRegistry) WriteMetrics(w io.Writer) error {
+	stats := r.Stats()
+
+	jobs := metrics.Family{
+		Name: "worker_jobs",
+		Help: "Jobs held by the registry by status",
+		Type: metrics.Gauge,
+	}
+	for _, status := range []JobStatus{JobQueued, JobRunning, JobDone, JobFailed} {
+		jobs.Samples = append(jobs.Samples, metrics.Sample{
+			Labels: map[string]string{"status": string(status)},
+			Value:  float64(stats.Jobs[status]),
+		})
+	}
+
+	buckets := make([]metrics.Bucket, len(stats.Duration.Buckets))
+	for i, bucket := range stats.Duration.Buckets {
+		buckets[i] = metrics.Bucket{UpperBound: bucket.UpperBound, Count: bucket.Count}
+	}
+
+	return metrics.Write(w,
+		metrics.NewGauge("worker_queue_depth", "Jobs waiting to be processed", float64(stats.QueueDepth)),
+		jobs,
+		metrics.NewHistogram("worker_job_duration_seconds", "Time from a job starting to finishing",
+			buckets, stats.Duration.Count, stats.Duration.Sum),
+	)
+}
+
+// pruneIfDue prunes at most twice per TTL so lookups stay cheap. The caller
+// must hold the lock.
+func (r *JobRegistry) pruneIfDue(now time.Time) {
+	if now.Sub(r.lastPrune) >= r.ttl/2 {
+		r.prune(now)
+	}
+}
+
+// prune removes expired jobs. The caller must hold the lock.
+func (r *JobRegistry) prune(now time.Time) int {
+	removed := 0
+	for id, job := range r.jobs {
+		if r.expired(job, now) {
+			delete(r.jobs, id)
+			removed++
+		}
+	}
+	r.lastPrune = now
+	return removed
+}
+
+// expired reports whether a finished job has outlived the TTL
+func (r *JobRegistry) expired(job *Job, now time.Time) bool {
+	return job.finished() && now.Sub(*job.FinishedAt) > r.ttl
+}
+
+// observeDuration adds a job duration to the histogram. The caller must hold
+// the lock.
+func (r *JobRegistry) observeDuration(duration time.Duration) {
+	seconds := duration.Seconds()
+	r.durations.Count++
+	r.durations.Sum += seconds
+	for i := range r.durations.Buckets {
+		if seconds <= r.durations.Buckets[i].UpperBound {
+			r.durations.Buckets[i].Count++
+		}
+	}
+}
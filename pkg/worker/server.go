@@ -6,48 +6,95 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/metrics"
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
+// ShutdownTimeout bounds each stage of a server shutdown
+const ShutdownTimeout = 10 * time.Second
+
 // Server implements the worker service
 type Server struct {
 	logger     logging.Logger
 	workerPool *WorkerPool
 	server     *http.Server
-	lock       sync.RWMutex
-	tasks      map[string]Task
+
+	checkpointStore  CheckpointStore  // Enables checkpointed workers when set
+	checkpointConfig CheckpointConfig
+	segmentProcessor SegmentProcessor // Segment function of checkpointed workers
+}
+
+// ServerOption is a function that configures a Server
+type ServerOption func(*Server)
+
+// WithCheckpointing makes the server's workers checkpoint job segments to
+// store, so a job resubmitted after a restart resumes where it stopped
+func WithCheckpointing(store CheckpointStore, config CheckpointConfig) ServerOption {
+	return func(s *Server) {
+		s.checkpointStore = store
+		s.checkpointConfig = config
+	}
+}
+
+// WithSegmentProcessor sets the function checkpointed workers apply to each
+// segment of a job. Segments are hashed by default.
+func WithSegmentProcessor(process SegmentProcessor) ServerOption {
+	return func(s *Server) {
+		s.segmentProcessor = process
+	}
+}
+
+// WithJobTTL sets how long finished jobs and their results are retained
+func WithJobTTL(ttl time.Duration) ServerOption {
+	return func(s *Server) {
+		s.workerPool.Jobs().SetTTL(ttl)
+	}
 }
 
 // NewServer creates a new worker server
-func NewServer(logger logging.Logger, addr string, numWorkers int) *Server {
+func NewServer(logger logging.Logger, addr string, numWorkers int, options ...ServerOption) *Server {
 	workerPool := NewWorkerPool(logger, 100) // Buffer for 100 tasks
 	
-	// Create default workers
-	for i := 0; i < numWorkers; i++ {
-		workerID := fmt.Sprintf("worker-%d", i)
-		worker := NewDefaultWorker(workerID, logger)
-		workerPool.AddWorker(workerID, worker)
-	}
-	
 	s := &Server{
 		logger:     logger,
 		workerPool: workerPool,
-		tasks:      make(map[string]Task),
+	}
+	
+	// Apply options
+	for _, option := range options {
+		option(s)
+	}
+	
+	// Create default workers
+	for i := 0; i < numWorkers; i++ {
+		workerID := fmt.Sprintf("worker-%d", i)
+		if s.checkpointStore != nil {
+			workerPool.AddWorker(workerID, NewCheckpointedWorker(workerID, logger, s.checkpointStore, s.checkpointConfig, s.segmentProcessor))
+		} else {
+			workerPool.AddWorker(workerID, NewDefaultWorker(workerID, logger))
+		}
 	}
 	
 	router := mux.NewRouter()
 	router.HandleFunc("/tasks", s.handleSubmitTask).Methods(http.MethodPost)
 	router.HandleFunc("/tasks/{id}", s.handleGetTaskResult).Methods(http.MethodGet)
+	router.HandleFunc("/jobs", s.handleListJobs).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{id}", s.handleGetJob).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{id}", s.handleDeleteJob).Methods(http.MethodDelete)
+	router.HandleFunc("/stats", s.handleStats).Methods(http.MethodGet)
+	router.HandleFunc("/metrics", s.handleMetrics).Methods(http.MethodGet)
 	router.HandleFunc("/health", s.handleHealth).Methods(http.MethodGet)
 	router.HandleFunc("/readiness", s.handleReadiness).Methods(http.MethodGet)
 	
@@ -70,11 +117,11 @@ func (s *Server) Start(ctx context.Context) error {
 	// Start the HTTP server
 	go func() {
 		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.logger.Error("Server error: %s", err)
+			s.logger.Error("Server error", zap.Error(err))
 		}
 	}()
 	
-	s.logger.Info("Server started on %s", s.server.Addr)
+	s.logger.Info("Server started", zap.String("addr", s.server.Addr))
 	
 	// Wait for shutdown signal
 	stop := make(chan os.Signal, 1)
@@ -88,17 +135,20 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 	
 	// Create a shutdown context with timeout
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
 	defer cancel()
 	
 	// Shutdown the server
 	if err := s.server.Shutdown(shutdownCtx); err != nil {
-		s.logger.Error("Server shutdown error: %s", err)
+		s.logger.Error("Server shutdown error", zap.Error(err))
 		return err
 	}
 	
-	// Stop the worker pool
-	s.workerPool.Stop()
+	// Stop the worker pool, draining queued tasks
+	if err := s.workerPool.Stop(ShutdownTimeout); err != nil {
+		s.logger.Error("Worker pool shutdown error", zap.Error(err))
+		return err
+	}
 	
 	s.logger.Info("Server stopped gracefully")
 	return nil
@@ -112,20 +162,33 @@ func (s *Server) handleSubmitTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	taskID := uuid.New().String()
+	taskID := strings.TrimSpace(req.TaskID)
+	if taskID == "" {
+		taskID = uuid.New().String()
+	}
 	task := Task{
 		ID:        taskID,
 		Payload:   req.Payload,
 		StartTime: time.Now(),
 	}
 	
-	// Store the task
-	s.lock.Lock()
-	s.tasks[taskID] = task
-	s.lock.Unlock()
-	
 	// Submit the task to the worker pool
-	s.workerPool.SubmitTask(task)
+	job, created, err := s.workerPool.SubmitTask(task)
+	if err != nil {
+		// The client retries 503 responses
+		http.Error(w, fmt.Sprintf("Task not accepted: %s", err), http.StatusServiceUnavailable)
+		return
+	}
+	if !created {
+		// Duplicate submission, report the existing job instead of re-running it
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(TaskResponse{
+			TaskID: job.ID,
+			Status: string(job.Status),
+		})
+		return
+	}
 	
 	// Return the task ID
 	resp := TaskResponse{
@@ -144,11 +207,7 @@ func (s *Server) handleGetTaskResult(w http.ResponseWriter, r *http.Request) {
 	taskID := vars["id"]
 	
 	// Check if the task exists
-	s.lock.RLock()
-	_, exists := s.tasks[taskID]
-	s.lock.RUnlock()
-	
-	if !exists {
+	if _, exists := s.workerPool.Jobs().Get(taskID); !exists {
 		http.Error(w, fmt.Sprintf("Task not found: %s", taskID), http.StatusNotFound)
 		return
 	}
@@ -157,11 +216,16 @@ func (s *Server) handleGetTaskResult(w http.ResponseWriter, r *http.Request) {
 	result, found := s.workerPool.GetResult(taskID)
 	if !found {
 		// Task exists but result not ready
+		resp := map[string]interface{}{
+			"status": "processing",
+		}
+		if progress, ok := s.taskProgress(taskID); ok {
+			resp["progress"] = progress
+		}
+		
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "processing",
-		})
+		json.NewEncoder(w).Encode(resp)
 		return
 	}
 	
@@ -171,6 +235,98 @@ func (s *Server) handleGetTaskResult(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// taskProgress returns the progress of an in-flight checkpointed task
+func (s *Server) taskProgress(taskID string) (JobProgress, bool) {
+	for _, worker := range s.workerPool.GetWorkers() {
+		if cw, ok := worker.(*CheckpointedWorker); ok {
+			if progress, found := cw.Progress(taskID); found {
+				return progress, true
+			}
+		}
+	}
+	return JobProgress{}, false
+}
+
+// handleListJobs handles job listing, optionally filtered by status
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	status := JobStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", JobQueued, JobRunning, JobDone, JobFailed:
+	default:
+		http.Error(w, fmt.Sprintf("Invalid status: %s", status), http.StatusBadRequest)
+		return
+	}
+	
+	jobs := s.workerPool.Jobs().List(status)
+	for i := range jobs {
+		s.attachProgress(&jobs[i])
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs": jobs,
+	})
+}
+
+// handleGetJob handles job status retrieval
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	
+	job, exists := s.workerPool.Jobs().Get(jobID)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Job not found: %s", jobID), http.StatusNotFound)
+		return
+	}
+	s.attachProgress(&job)
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleDeleteJob handles job removal
+func (s *Server) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	
+	switch err := s.workerPool.Jobs().Delete(jobID); {
+	case errors.Is(err, ErrJobNotFound):
+		http.Error(w, fmt.Sprintf("Job not found: %s", jobID), http.StatusNotFound)
+	case errors.Is(err, ErrJobRunning):
+		http.Error(w, fmt.Sprintf("Job is running: %s", jobID), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleStats handles requests for queue depth and job duration statistics
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.workerPool.Jobs().Stats())
+}
+
+// handleMetrics handles Prometheus scrapes of the job metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := s.workerPool.Jobs().WriteMetrics(w); err != nil {
+		s.logger.Debug("Failed to write metrics", zap.Error(err))
+	}
+}
+
+// attachProgress adds the progress of a running checkpointed job
+func (s *Server) attachProgress(job *Job) {
+	if job.Status != JobRunning {
+		return
+	}
+	if progress, ok := s.taskProgress(job.ID); ok {
+		job.Progress = &progress
+	}
+}
+
 // handleHealth handles health check requests
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
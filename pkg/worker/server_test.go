@@ -0,0 +1,373 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/metrics"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingWorker echoes the payload and counts processed tasks
+type countingWorker struct {
+	processed int32
+}
+
+func (w *countingWorker) ProcessTask(ctx context.Context, task Task) (Result, error) {
+	atomic.AddInt32(&w.processed, 1)
+	return Result{TaskID: task.ID, Output: task.Payload, StartTime: task.StartTime, EndTime: time.Now()}, nil
+}
+
+// newTestServer starts a server's worker pool with a single worker and
+// serves its API over httptest
+func newTestServer(t *testing.T, worker Worker, goroutines int, options ...ServerOption) (*Server, *httptest.Server) {
+	s := NewServer(logging.NoLog{}, "", 0, options...)
+	s.workerPool.AddWorker("worker-0", worker)
+	s.workerPool.Start(context.Background(), goroutines)
+
+	api := httptest.NewServer(s.server.Handler)
+	t.Cleanup(func() {
+		api.Close()
+		_ = s.workerPool.Stop(time.Second)
+	})
+	return s, api
+}
+
+func submitJob(t *testing.T, api *httptest.Server, req TaskRequest) (int, TaskResponse) {
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	resp, err := http.Post(api.URL+"/tasks", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var taskResp TaskResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&taskResp))
+	return resp.StatusCode, taskResp
+}
+
+func getJob(t *testing.T, api *httptest.Server, id string) (int, Job) {
+	resp, err := http.Get(api.URL + "/jobs/" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var job Job
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	}
+	return resp.StatusCode, job
+}
+
+func deleteJob(t *testing.T, api *httptest.Server, id string) int {
+	req, err := http.NewRequest(http.MethodDelete, api.URL+"/jobs/"+id, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func waitForStatus(t *testing.T, api *httptest.Server, id string, status JobStatus) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		_, job = getJob(t, api, id)
+		return job.Status == status
+	}, 5*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestJobLifecycleThroughAPI(t *testing.T) {
+	_, api := newTestServer(t, &countingWorker{}, 2)
+
+	code, submitted := submitJob(t, api, TaskRequest{Payload: []byte("vertex")})
+	require.Equal(t, http.StatusAccepted, code)
+	require.NotEmpty(t, submitted.TaskID)
+
+	job := waitForStatus(t, api, submitted.TaskID, JobDone)
+	assert.Equal(t, []byte("vertex"), job.Output)
+	require.NotNil(t, job.StartedAt)
+	require.NotNil(t, job.FinishedAt)
+	assert.False(t, job.FinishedAt.Before(*job.StartedAt))
+
+	resp, err := http.Get(api.URL + "/jobs?status=done")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var listed struct {
+		Jobs []Job `json:"jobs"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	require.Len(t, listed.Jobs, 1)
+	assert.Equal(t, submitted.TaskID, listed.Jobs[0].ID)
+
+	resp, err = http.Get(api.URL + "/jobs?status=bogus")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	code, _ = getJob(t, api, "unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestDuplicateJobIDIsNotReExecuted(t *testing.T) {
+	worker := &countingWorker{}
+	_, api := newTestServer(t, worker, 1)
+
+	code, first := submitJob(t, api, TaskRequest{TaskID: "job-1", Payload: []byte("a")})
+	require.Equal(t, http.StatusAccepted, code)
+	waitForStatus(t, api, "job-1", JobDone)
+
+	code, second := submitJob(t, api, TaskRequest{TaskID: "job-1", Payload: []byte("b")})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, first.TaskID, second.TaskID)
+	assert.Equal(t, string(JobDone), second.Status)
+
+	_, job := getJob(t, api, "job-1")
+	assert.Equal(t, []byte("a"), job.Output)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&worker.processed))
+}
+
+func TestFinishedJobsExpireAfterTTL(t *testing.T) {
+	s, api := newTestServer(t, &countingWorker{}, 1, WithJobTTL(50*time.Millisecond))
+
+	_, submitted := submitJob(t, api, TaskRequest{Payload: []byte("x")})
+	waitForStatus(t, api, submitted.TaskID, JobDone)
+
+	require.Eventually(t, func() bool {
+		code, _ := getJob(t, api, submitted.TaskID)
+		return code == http.StatusNotFound
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The record itself is removed, not just hidden
+	assert.Empty(t, s.workerPool.Jobs().List(""))
+	assert.Equal(t, 0, s.workerPool.Jobs().Prune(time.Now()))
+}
+
+func TestExpiredJobIsRegisteredAgain(t *testing.T) {
+	registry := NewJobRegistry(time.Hour)
+	job, created := registry.Register("job-1", time.Now())
+	require.True(t, created)
+	require.True(t, registry.Start("job-1", job.generation))
+	registry.Finish(Result{TaskID: "job-1", Output: []byte("stale")})
+
+	// Expired, but the next prune is not due yet
+	finishedAt := time.Now().Add(-61 * time.Minute)
+	registry.jobs["job-1"].FinishedAt = &finishedAt
+	registry.lastPrune = time.Now()
+
+	job, created = registry.Register("job-1", time.Now())
+	assert.True(t, created)
+	assert.Equal(t, JobQueued, job.Status)
+	assert.Empty(t, job.Output)
+}
+
+// gatedWorker holds the task "gate" until release is closed
+type gatedWorker struct {
+	countingWorker
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *gatedWorker) ProcessTask(ctx context.Context, task Task) (Result, error) {
+	if task.ID == "gate" {
+		close(w.started)
+		<-w.release
+	}
+	return w.countingWorker.ProcessTask(ctx, task)
+}
+
+func TestResubmittedJobRunsOnce(t *testing.T) {
+	worker := &gatedWorker{started: make(chan struct{}), release: make(chan struct{})}
+	_, api := newTestServer(t, worker, 1)
+
+	submitJob(t, api, TaskRequest{TaskID: "gate"})
+	<-worker.started
+
+	// Both copies of the job are queued behind the gate
+	submitJob(t, api, TaskRequest{TaskID: "job-1", Payload: []byte("deleted")})
+	require.Equal(t, http.StatusNoContent, deleteJob(t, api, "job-1"))
+	code, _ := submitJob(t, api, TaskRequest{TaskID: "job-1", Payload: []byte("resubmitted")})
+	require.Equal(t, http.StatusAccepted, code)
+	submitJob(t, api, TaskRequest{TaskID: "last"})
+
+	// The copy queued for the deleted job is skipped
+	close(worker.release)
+	waitForStatus(t, api, "last", JobDone)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&worker.processed))
+	_, job := getJob(t, api, "job-1")
+	assert.Equal(t, []byte("resubmitted"), job.Output)
+}
+
+func TestSubmitRefusedWhenPoolCannotQueue(t *testing.T) {
+	post := func(api *httptest.Server, id string) int {
+		body, err := json.Marshal(TaskRequest{TaskID: id})
+		require.NoError(t, err)
+		resp, err := http.Post(api.URL+"/tasks", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The only queue slot is taken while the worker holds the gate
+	worker := &gatedWorker{started: make(chan struct{}), release: make(chan struct{})}
+	s := NewServer(logging.NoLog{}, "", 0)
+	s.workerPool = NewWorkerPool(logging.NoLog{}, 1)
+	s.workerPool.AddWorker("worker-0", worker)
+	s.workerPool.Start(context.Background(), 1)
+	api := httptest.NewServer(s.server.Handler)
+	defer api.Close()
+
+	require.Equal(t, http.StatusAccepted, post(api, "gate"))
+	<-worker.started
+	require.Equal(t, http.StatusAccepted, post(api, "queued"))
+	assert.Equal(t, http.StatusServiceUnavailable, post(api, "overflow"))
+	code, _ := getJob(t, api, "overflow")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// After Stop, submissions are refused instead of panicking on the closed queue
+	close(worker.release)
+	require.NoError(t, s.workerPool.Stop(time.Second))
+	assert.Equal(t, http.StatusServiceUnavailable, post(api, "late"))
+	_, _, err := s.workerPool.SubmitTask(Task{ID: "late"})
+	assert.ErrorIs(t, err, ErrPoolStopped)
+	code, _ = getJob(t, api, "late")
+	assert.Equal(t, http.StatusNotFound, code)
+	waitForStatus(t, api, "queued", JobDone)
+}
+
+func TestDeleteJob(t *testing.T) {
+	worker := &blockingWorker{started: make(chan string, 2)}
+	s, api := newTestServer(t, worker, 1)
+
+	_, running := submitJob(t, api, TaskRequest{TaskID: "running"})
+	<-worker.started
+	_, queued := submitJob(t, api, TaskRequest{TaskID: "queued"})
+
+	assert.Equal(t, http.StatusConflict, deleteJob(t, api, running.TaskID))
+	assert.Equal(t, http.StatusNoContent, deleteJob(t, api, queued.TaskID))
+	assert.Equal(t, http.StatusNotFound, deleteJob(t, api, queued.TaskID))
+
+	// The deleted job is skipped when it is dequeued
+	require.NoError(t, s.workerPool.Stop(20*time.Millisecond))
+	assert.Len(t, worker.started, 0)
+	code, _ := getJob(t, api, queued.TaskID)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestStatsReportQueueDepthAndDurations(t *testing.T) {
+	worker := &blockingWorker{started: make(chan string, 3)}
+	s, api := newTestServer(t, worker, 1)
+
+	for _, id := range []string{"a", "b", "c"} {
+		submitJob(t, api, TaskRequest{TaskID: id})
+	}
+	<-worker.started
+
+	stats := s.workerPool.Jobs().Stats()
+	assert.Equal(t, 2, stats.QueueDepth)
+	assert.Equal(t, 1, stats.Jobs[JobRunning])
+
+	_, api = newTestServer(t, &countingWorker{}, 2)
+	for _, id := range []string{"a", "b", "c"} {
+		submitJob(t, api, TaskRequest{TaskID: id})
+		waitForStatus(t, api, id, JobDone)
+	}
+
+	resp, err := http.Get(api.URL + "/stats")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+
+	assert.Equal(t, 0, stats.QueueDepth)
+	assert.Equal(t, 3, stats.Jobs[JobDone])
+	assert.Equal(t, uint64(3), stats.Duration.Count)
+	require.Len(t, stats.Duration.Buckets, len(jobDurationBuckets))
+	assert.Equal(t, uint64(3), stats.Duration.Buckets[len(jobDurationBuckets)-1].Count)
+
+	// The same figures are exposed to Prometheus
+	resp, err = http.Get(api.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, metrics.ContentType, resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	for _, line := range []string{
+		"# TYPE worker_queue_depth gauge\nworker_queue_depth 0\n",
+		`worker_jobs{status="done"} 3`,
+		"# TYPE worker_job_duration_seconds histogram\n",
+		`worker_job_duration_seconds_bucket{le="+Inf"} 3`,
+		"worker_job_duration_seconds_count 3\n",
+	} {
+		assert.Contains(t, string(body), line)
+	}
+}
+
+func TestCheckpointedServerResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	config := CheckpointConfig{SegmentSize: 4, FlushInterval: 1}
+	payload := testPayload(32, config.SegmentSize)
+
+	reference := NewCheckpointedWorker("reference", logging.NoLog{}, NewMemoryCheckpointStore(), config, nil)
+	expected, err := reference.ProcessTask(context.Background(), Task{ID: "reference", Payload: payload})
+	require.NoError(t, err)
+
+	// Each server opens the same checkpoint directory, as a restarted worker would
+	startServer := func(process SegmentProcessor) (*Server, *httptest.Server) {
+		store, err := NewFileCheckpointStore(dir)
+		require.NoError(t, err)
+
+		s := NewServer(logging.NoLog{}, "", 1, WithCheckpointing(store, config), WithSegmentProcessor(process))
+		s.workerPool.Start(context.Background(), 1)
+		api := httptest.NewServer(s.server.Handler)
+		t.Cleanup(func() {
+			api.Close()
+			_ = s.workerPool.Stop(time.Second)
+		})
+		return s, api
+	}
+
+	// The first worker is stopped while it processes segment 10
+	reached := make(chan struct{})
+	first, api := startServer(func(ctx context.Context, index int, segment []byte) ([]byte, error) {
+		if index == 10 {
+			close(reached)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return hashSegment(ctx, index, segment)
+	})
+	submitJob(t, api, TaskRequest{TaskID: "job-1", Payload: payload})
+	<-reached
+	require.NoError(t, first.workerPool.Stop(20*time.Millisecond))
+	_, job := getJob(t, api, "job-1")
+	require.Equal(t, JobFailed, job.Status)
+
+	// The restarted worker only processes the segments after the checkpoint
+	var processed int32
+	second, api := startServer(func(ctx context.Context, index int, segment []byte) ([]byte, error) {
+		atomic.AddInt32(&processed, 1)
+		return hashSegment(ctx, index, segment)
+	})
+	code, _ := submitJob(t, api, TaskRequest{TaskID: "job-1", Payload: payload})
+	require.Equal(t, http.StatusAccepted, code)
+
+	job = waitForStatus(t, api, "job-1", JobDone)
+	assert.Equal(t, expected.Output, job.Output)
+	assert.Equal(t, int32(32-10), atomic.LoadInt32(&processed))
+
+	result, found := second.workerPool.GetResult("job-1")
+	require.True(t, found)
+	assert.True(t, result.Progress.ResumedFromCheckpoint)
+	assert.Equal(t, 32, result.Progress.SegmentsDone)
+}
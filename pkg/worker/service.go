@@ -5,12 +5,25 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
-	"github.com/ava-labs/avalanchego/ids"
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/shutdown"
 	"github.com/ava-labs/avalanchego/utils/logging"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrPoolStopped is returned for tasks submitted after the pool was stopped
+	ErrPoolStopped = errors.New("worker pool stopped")
+
+	// ErrQueueFull is returned for tasks submitted while the task queue is full
+	ErrQueueFull = errors.New("task queue full")
+
+	// errNoWorkers is recorded for tasks dequeued while the pool has no workers
+	errNoWorkers = errors.New("no workers available")
 )
 
 // Task represents a unit of work to be processed
@@ -27,6 +40,7 @@ type Result struct {
 	Error     error
 	StartTime time.Time
 	EndTime   time.Time
+	Progress  *JobProgress // Set by checkpointed workers
 }
 
 // Worker defines the interface for task processors
@@ -50,8 +64,6 @@ func NewDefaultWorker(id string, logger logging.Logger) *DefaultWorker {
 
 // ProcessTask handles the processing of a task
 func (w *DefaultWorker) ProcessTask(ctx context.Context, task Task) (Result, error) {
-	startTime := time.Now()
-	
 	// Process the task (implement the actual processing logic)
 	// This is just a placeholder
 	time.Sleep(100 * time.Millisecond)
@@ -66,22 +78,31 @@ func (w *DefaultWorker) ProcessTask(ctx context.Context, task Task) (Result, err
 	return result, nil
 }
 
+// queuedTask is a task waiting in the queue for the job it was submitted as
+type queuedTask struct {
+	task       Task
+	generation uint64 // Generation of the job registration
+}
+
 // WorkerPool manages a pool of workers
 type WorkerPool struct {
-	lock     sync.RWMutex
-	workers  map[string]Worker
-	taskChan chan Task
-	results  map[string]Result
-	logger   logging.Logger
-	wg       sync.WaitGroup
+	lock      sync.RWMutex
+	workers   map[string]Worker
+	queueLock sync.Mutex // Guards stopped and sends on taskChan
+	stopped   bool
+	taskChan  chan queuedTask
+	jobs      *JobRegistry
+	logger    logging.Logger
+	loops     *shutdown.Group
+	stopOnce  sync.Once
 }
 
 // NewWorkerPool creates a new worker pool
 func NewWorkerPool(logger logging.Logger, capacity int) *WorkerPool {
 	return &WorkerPool{
 		workers:  make(map[string]Worker),
-		taskChan: make(chan Task, capacity),
-		results:  make(map[string]Result),
+		taskChan: make(chan queuedTask, capacity),
+		jobs:     NewJobRegistry(DefaultJobTTL),
 		logger:   logger,
 	}
 }
@@ -116,24 +137,58 @@ func (wp *WorkerPool) GetWorkers() map[string]Worker {
 	return workersCopy
 }
 
-// SubmitTask submits a task to the worker pool
-func (wp *WorkerPool) SubmitTask(task Task) {
-	wp.taskChan <- task
+// SubmitTask submits a task to the worker pool. A task whose ID is already
+// registered is not queued again; the existing job is returned instead.
+// Submission never blocks: once the pool is stopped tasks are refused with
+// ErrPoolStopped, and while the queue is full with ErrQueueFull.
+func (wp *WorkerPool) SubmitTask(task Task) (Job, bool, error) {
+	wp.queueLock.Lock()
+	defer wp.queueLock.Unlock()
+
+	if wp.stopped {
+		return Job{}, false, ErrPoolStopped
+	}
+	job, created := wp.jobs.Register(task.ID, task.StartTime)
+	if !created {
+		return job, false, nil
+	}
+
+	select {
+	case wp.taskChan <- queuedTask{task: task, generation: job.generation}:
+		return job, true, nil
+	default:
+		// The job was never queued, so it must not be reported as queued
+		wp.jobs.Unregister(task.ID, job.generation)
+		return Job{}, false, ErrQueueFull
+	}
 }
 
-// Start starts the worker pool
+// Jobs returns the registry of submitted jobs
+func (wp *WorkerPool) Jobs() *JobRegistry {
+	return wp.jobs
+}
+
+// Start starts the worker pool. The workers are not cancelled with ctx: they
+// keep processing queued tasks until Stop drains them, so cancelling ctx does
+// not drop work.
 func (wp *WorkerPool) Start(ctx context.Context, numWorkers int) {
 	// Start worker goroutines
+	wp.loops = shutdown.NewGroup(context.WithoutCancel(ctx))
 	for i := 0; i < numWorkers; i++ {
-		wp.wg.Add(1)
-		go func() {
-			defer wp.wg.Done()
+		wp.loops.Go(func(ctx context.Context) {
 			for {
 				select {
-				case task, ok := <-wp.taskChan:
+				case queued, ok := <-wp.taskChan:
 					if !ok {
 						return
 					}
+					task := queued.task
+					
+					// Skip tasks deleted while they were queued, including
+					// copies left behind by a deleted and resubmitted job
+					if !wp.jobs.Start(task.ID, queued.generation) {
+						continue
+					}
 					
 					// Find an available worker
 					wp.lock.RLock()
@@ -144,7 +199,13 @@ func (wp *WorkerPool) Start(ctx context.Context, numWorkers int) {
 					wp.lock.RUnlock()
 					
 					if len(workers) == 0 {
-						wp.logger.Warn("No workers available to process task %s", task.ID)
+						wp.logger.Warn("No workers available to process task", zap.String("taskID", task.ID))
+						wp.jobs.Finish(Result{
+							TaskID:    task.ID,
+							Error:     errNoWorkers,
+							StartTime: task.StartTime,
+							EndTime:   time.Now(),
+						})
 						continue
 					}
 					
@@ -155,34 +216,48 @@ func (wp *WorkerPool) Start(ctx context.Context, numWorkers int) {
 					// Process the task
 					result, err := worker.ProcessTask(ctx, task)
 					if err != nil {
-						wp.logger.Error("Failed to process task %s: %s", task.ID, err)
+						wp.logger.Error("Failed to process task", zap.String("taskID", task.ID), zap.Error(err))
 						result.Error = err
 					}
 					
 					// Store the result
-					wp.lock.Lock()
-					wp.results[task.ID] = result
-					wp.lock.Unlock()
+					wp.jobs.Finish(result)
 					
 				case <-ctx.Done():
 					return
 				}
 			}
-		}()
+		})
 	}
 }
 
-// Stop stops the worker pool
-func (wp *WorkerPool) Stop() {
-	close(wp.taskChan)
-	wp.wg.Wait()
+// Stop stops accepting tasks and waits up to timeout for queued tasks to
+// drain. Tasks still running after that are cancelled, and Stop waits up to
+// timeout again for them to return.
+func (wp *WorkerPool) Stop(timeout time.Duration) error {
+	wp.stopOnce.Do(func() {
+		wp.queueLock.Lock()
+		defer wp.queueLock.Unlock()
+
+		wp.stopped = true
+		close(wp.taskChan)
+	})
+	if wp.loops == nil {
+		return nil
+	}
+
+	if err := wp.loops.Wait(timeout); err == nil {
+		return nil
+	}
+	wp.logger.Warn("Worker pool did not drain in time, cancelling in-flight tasks", zap.Duration("timeout", timeout))
+	return wp.loops.Shutdown(timeout)
 }
 
 // GetResult returns the result for a specific task
 func (wp *WorkerPool) GetResult(taskID string) (Result, bool) {
-	wp.lock.RLock()
-	defer wp.lock.RUnlock()
-	
-	result, found := wp.results[taskID]
-	return result, found
-} 
+	job, found := wp.jobs.Get(taskID)
+	if !found || !job.finished() {
+		return Result{}, false
+	}
+	return job.result, true
+} 
\ No newline at end of file
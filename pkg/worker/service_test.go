@@ -0,0 +1,99 @@
+// Copyright (C) 2024, Avalanche Parallel Project. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Final-Project-13520137/avalanche-parallel-dag/pkg/shutdown"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWorker processes tasks until its context is cancelled
+type blockingWorker struct {
+	started chan string
+}
+
+func (w *blockingWorker) ProcessTask(ctx context.Context, task Task) (Result, error) {
+	w.started <- task.ID
+	<-ctx.Done()
+	return Result{TaskID: task.ID}, ctx.Err()
+}
+
+func TestWorkerPoolParentCancellationKeepsQueuedTasks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	worker := &gatedWorker{started: make(chan struct{}), release: make(chan struct{})}
+	pool := NewWorkerPool(logging.NoLog{}, 10)
+	pool.AddWorker("worker-0", worker)
+	pool.Start(ctx, 1)
+
+	pool.SubmitTask(Task{ID: "gate"})
+	<-worker.started
+	for i := 0; i < 5; i++ {
+		pool.SubmitTask(Task{ID: fmt.Sprintf("task-%d", i)})
+	}
+
+	// The server cancels its context before stopping the pool
+	cancel()
+	close(worker.release)
+	require.NoError(t, pool.Stop(5*time.Second))
+
+	assert.Equal(t, int32(6), atomic.LoadInt32(&worker.processed))
+	for i := 0; i < 5; i++ {
+		result, found := pool.GetResult(fmt.Sprintf("task-%d", i))
+		require.True(t, found, "task-%d", i)
+		assert.NoError(t, result.Error)
+	}
+}
+
+func TestWorkerPoolStopDrainsQueuedTasks(t *testing.T) {
+	pool := NewWorkerPool(logging.NoLog{}, 10)
+	pool.AddWorker("worker-0", NewDefaultWorker("worker-0", logging.NoLog{}))
+	pool.Start(context.Background(), 2)
+
+	for i := 0; i < 5; i++ {
+		pool.SubmitTask(Task{ID: fmt.Sprintf("task-%d", i), StartTime: time.Now()})
+	}
+	require.NoError(t, pool.Stop(5*time.Second))
+
+	for i := 0; i < 5; i++ {
+		result, found := pool.GetResult(fmt.Sprintf("task-%d", i))
+		require.True(t, found, "task-%d", i)
+		assert.NoError(t, result.Error)
+	}
+}
+
+func TestWorkerPoolStopCancelsStuckTasks(t *testing.T) {
+	worker := &blockingWorker{started: make(chan string, 1)}
+	pool := NewWorkerPool(logging.NoLog{}, 10)
+	pool.AddWorker("worker-0", worker)
+	pool.Start(context.Background(), 1)
+
+	pool.SubmitTask(Task{ID: "task-0"})
+	<-worker.started
+
+	// The task never finishes on its own, so it is cancelled after the drain timeout
+	require.NoError(t, pool.Stop(20*time.Millisecond))
+	result, found := pool.GetResult("task-0")
+	require.True(t, found)
+	assert.ErrorIs(t, result.Error, context.Canceled)
+}
+
+func TestWorkerPoolExitsOnStopOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := NewWorkerPool(logging.NoLog{}, 10)
+	pool.Start(ctx, 3)
+
+	cancel()
+	assert.ErrorIs(t, pool.loops.Wait(20*time.Millisecond), shutdown.ErrTimeout)
+	require.NoError(t, pool.Stop(time.Second))
+}
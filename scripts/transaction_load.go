@@ -176,6 +176,7 @@ func main() {
 	benchmark := flag.Bool("benchmark", false, "Run parallel vs traditional benchmark")
 	scenarioTest := flag.Bool("scenarios", false, "Run different transaction scenarios")
 	transactionSize := flag.String("tx-size", "mixed", "Transaction size profile: small, medium, large, or mixed")
+	pipelineDepth := flag.Int("pipeline", 0, "Verification pipeline depth in blocks (0 disables the pipeline)")
 	flag.Parse()
 
 	fmt.Println("=== Avalanche Transaction Load Test ===")
@@ -184,7 +185,7 @@ func main() {
 	generateExtendedUsers()
 
 	if *benchmark {
-		runBenchmark(*numTransactions, *batchSize, *transactionSize)
+		runBenchmark(*numTransactions, *batchSize, *transactionSize, *pipelineDepth)
 		return
 	}
 
@@ -207,6 +208,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create blockchain: %v", err)
 	}
+	bc.SetVerificationPipeline(*pipelineDepth)
 
 	// Create test transactions
 	fmt.Printf("Generating %d test transactions...\n", *numTransactions)
@@ -256,17 +258,19 @@ func main() {
 	// Output results
 	fmt.Println("\n=== Results ===")
 	fmt.Printf("Processing mode: %s\n", modeString(*parallel, threads))
+	fmt.Printf("Pipeline depth: %d\n", *pipelineDepth)
 	fmt.Printf("Total transactions: %d\n", *numTransactions)
 	fmt.Printf("Blocks created: %d\n", blockCount)
 	fmt.Printf("Processing time: %v\n", duration)
 	fmt.Printf("Transactions per second: %.2f\n", txPerSecond)
 }
 
-func runBenchmark(numTransactions, batchSize int, sizeProfile string) {
+func runBenchmark(numTransactions, batchSize int, sizeProfile string, pipelineDepth int) {
 	fmt.Println("=== Parallel vs Traditional Benchmark ===")
 	fmt.Printf("Transaction size profile: %s\n", sizeProfile)
 	fmt.Printf("Number of transactions: %d\n", numTransactions)
 	fmt.Printf("Batch size: %d\n", batchSize)
+	fmt.Printf("Pipeline depth: %d\n", pipelineDepth)
 
 	// Create loggers
 	parallelLogger := &AvalancheLogger{}
@@ -283,6 +287,7 @@ func runBenchmark(numTransactions, batchSize int, sizeProfile string) {
 	if err != nil {
 		log.Fatalf("Failed to create sequential blockchain: %v", err)
 	}
+	bcSequential.SetVerificationPipeline(pipelineDepth)
 	
 	// Create test transactions based on size profile
 	fmt.Printf("Generating %d test transactions with %s profile...\n", numTransactions, sizeProfile)
@@ -313,6 +318,7 @@ func runBenchmark(numTransactions, batchSize int, sizeProfile string) {
 			log.Fatalf("Failed to create parallel blockchain with %d threads: %v", threads, err)
 			continue
 		}
+		bcParallel.SetVerificationPipeline(pipelineDepth)
 		
 		// Clone transactions
 		parallelTxs := cloneTransactions(transactions)
@@ -389,7 +395,8 @@ func createProfiledTransactions(count int, sizeProfile string) []*blockchain.Tra
 			amount = 100 + uint64(rand.Intn(900)) // Default medium
 		}
 		
-		nonce := uint64(i)
+		// Each sender uses consecutive nonces so the mempool never holds any back
+		nonce := uint64(i / numUsers)
 		
 		tx, err := blockchain.NewTransaction(sender, recipient, amount, nonce)
 		if err != nil {
@@ -539,7 +546,7 @@ func createTestTransactions(count int) []*blockchain.Transaction {
 		sender := fmt.Sprintf("user%d", i%100)
 		recipient := fmt.Sprintf("recipient%d", (i+50)%100)
 		amount := uint64(100 + i%900)
-		nonce := uint64(i)
+		nonce := uint64(i / 100) // Consecutive nonces per sender
 		
 		tx, err := blockchain.NewTransaction(sender, recipient, amount, nonce)
 		if err != nil {
@@ -721,40 +728,17 @@ func saveScenarioResults(scenarios []struct {
 
 // Helper functions to access blockchain information
 func getGenesisBlockID(bc *blockchain.Blockchain) ids.ID {
-	latestBlocks := bc.GetLatestBlocks()
-	if len(latestBlocks) == 0 {
-		// No blocks yet, create an empty ID
-		return ids.Empty
+	// The genesis block is the only block at height 0
+	genesis := bc.GetBlocksByHeight(0)
+	if len(genesis) == 0 {
+		log.Fatalf("Blockchain has no genesis block")
 	}
-	
-	// Access the genesis block through the parent of the latest block
-	genesis, err := bc.GetBlock(latestBlocks[0].ParentIDs[0])
-	if err != nil {
-		log.Fatalf("Failed to get genesis block: %v", err)
-	}
-	return genesis.ID()
+	return genesis[0].ID()
 }
 
+// getTxPoolSize returns how many transactions are ready to be included in a
+// block. Transactions waiting on a nonce gap are not counted, since no block
+// can take them.
 func getTxPoolSize(bc *blockchain.Blockchain) int {
-	// We can't directly access the txPool size, so use a workaround
-	// Create a block with all transactions and see how many are in it
-	parents := bc.GetLatestBlocks()
-	parentIDs := make([]ids.ID, len(parents))
-	for i, parent := range parents {
-		parentIDs[i] = parent.ID()
-	}
-	
-	// Try to create a block with a very large maxTxs to get all transactions
-	block, err := bc.CreateBlock(parentIDs, 10000)
-	if err != nil {
-		// If there are no transactions, it might error
-		return 0
-	}
-	
-	// If block was created, it consumed the transactions, so add them back
-	for _, tx := range block.Transactions {
-		bc.AddTransaction(tx)
-	}
-	
-	return len(block.Transactions)
-} 
\ No newline at end of file
+	return bc.GetMempoolInfo().Pending
+}